@@ -0,0 +1,193 @@
+package goreq
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer picks the endpoint of a request among the available endpoints of a pool
+type Balancer interface {
+	Pick(r *Req, endpoints []*EndpointState) *EndpointState
+}
+
+// BalancerFunc adapts a function to a Balancer
+type BalancerFunc func(r *Req, endpoints []*EndpointState) *EndpointState
+
+func (f BalancerFunc) Pick(r *Req, endpoints []*EndpointState) *EndpointState {
+	return f(r, endpoints)
+}
+
+// RoundRobin picks the endpoints in turn
+func RoundRobin() Balancer {
+	var next uint64
+	return BalancerFunc(func(r *Req, endpoints []*EndpointState) *EndpointState {
+		return endpoints[(atomic.AddUint64(&next, 1)-1)%uint64(len(endpoints))]
+	})
+}
+
+// WeightedRoundRobin picks the endpoints in turn in proportion to their weights,
+// spreading the picks of heavy endpoints like the smooth weighted round robin of nginx
+func WeightedRoundRobin() Balancer {
+	return &weightedRoundRobin{current: make(map[string]int)}
+}
+
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func (b *weightedRoundRobin) Pick(r *Req, endpoints []*EndpointState) *EndpointState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var best *EndpointState
+	total := 0
+	for _, e := range endpoints {
+		total += e.weight()
+		b.current[e.URL] += e.weight()
+		if best == nil || b.current[e.URL] > b.current[best.URL] {
+			best = e
+		}
+	}
+	b.current[best.URL] -= total
+	// forget the endpoints which are gone, like the ones removed by a resolver update
+	if len(b.current) > len(endpoints) {
+		picked := make(map[string]bool, len(endpoints))
+		for _, e := range endpoints {
+			picked[e.URL] = true
+		}
+		for url := range b.current {
+			if !picked[url] {
+				delete(b.current, url)
+			}
+		}
+	}
+	return best
+}
+
+// LeastInFlight picks the endpoint with the fewest requests in flight relative to its weight
+func LeastInFlight() Balancer {
+	return BalancerFunc(func(r *Req, endpoints []*EndpointState) *EndpointState {
+		var best *EndpointState
+		for _, e := range endpoints {
+			if best == nil || lessLoaded(e, best) {
+				best = e
+			}
+		}
+		return best
+	})
+}
+
+// PowerOfTwoChoices picks the less loaded of two random endpoints,
+// which avoids the herding of LeastInFlight when many clients share the endpoints
+func PowerOfTwoChoices() Balancer {
+	return BalancerFunc(func(r *Req, endpoints []*EndpointState) *EndpointState {
+		if len(endpoints) == 1 {
+			return endpoints[0]
+		}
+		i := rand.Intn(len(endpoints))
+		j := rand.Intn(len(endpoints) - 1)
+		if j >= i {
+			j++
+		}
+		if lessLoaded(endpoints[j], endpoints[i]) {
+			return endpoints[j]
+		}
+		return endpoints[i]
+	})
+}
+
+func lessLoaded(a, b *EndpointState) bool {
+	return a.InFlight()*int64(b.weight()) < b.InFlight()*int64(a.weight())
+}
+
+// ConsistentHash picks the endpoint of the key of a request on a hash ring, with replicas virtual nodes per weight,
+// so that a key sticks to its endpoint and only the keys of an endpoint joining or leaving are remapped.
+// Requests with an empty key are balanced with PowerOfTwoChoices.
+func ConsistentHash(keyFunc func(*Req) string, replicas int) Balancer {
+	if replicas <= 0 {
+		replicas = 160
+	}
+	fallback := PowerOfTwoChoices()
+	var mu sync.Mutex
+	var cached *hashRing
+	return BalancerFunc(func(r *Req, endpoints []*EndpointState) *EndpointState {
+		key := keyFunc(r)
+		if key == "" {
+			return fallback.Pick(r, endpoints)
+		}
+		mu.Lock()
+		if cached == nil || !cached.matches(endpoints) {
+			cached = newHashRing(endpoints, replicas)
+		}
+		ring := cached
+		mu.Unlock()
+		return ring.get(key)
+	})
+}
+
+// hashRing is immutable once built
+type hashRing struct {
+	endpoints []*EndpointState
+	hashes    []uint64
+	nodes     []*EndpointState
+}
+
+func newHashRing(endpoints []*EndpointState, replicas int) *hashRing {
+	ring := &hashRing{endpoints: append([]*EndpointState(nil), endpoints...)}
+	type vnode struct {
+		hash     uint64
+		endpoint *EndpointState
+	}
+	var vnodes []vnode
+	for _, e := range endpoints {
+		for i := 0; i < replicas*e.weight(); i++ {
+			vnodes = append(vnodes, vnode{hash: hashKey(e.URL + "#" + strconv.Itoa(i)), endpoint: e})
+		}
+	}
+	sort.Slice(vnodes, func(i, j int) bool { return vnodes[i].hash < vnodes[j].hash })
+	ring.hashes = make([]uint64, len(vnodes))
+	ring.nodes = make([]*EndpointState, len(vnodes))
+	for i, v := range vnodes {
+		ring.hashes[i], ring.nodes[i] = v.hash, v.endpoint
+	}
+	return ring
+}
+
+func (ring *hashRing) matches(endpoints []*EndpointState) bool {
+	if len(endpoints) != len(ring.endpoints) {
+		return false
+	}
+	for i, e := range endpoints {
+		if e != ring.endpoints[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the endpoint of the first virtual node after the hash of key
+func (ring *hashRing) get(key string) *EndpointState {
+	h := hashKey(key)
+	i := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= h })
+	if i == len(ring.hashes) {
+		i = 0
+	}
+	return ring.nodes[i]
+}
+
+// hashKey is fnv-1a, mixed with the finalizer of splitmix64 for similar keys to spread over the ring
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
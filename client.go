@@ -1,9 +1,13 @@
 package goreq
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +20,16 @@ type Client interface {
 	Options() Options
 	Clone(opts ...Option) Client
 	Use(...HandlerFunc) Client
+	UseNamed(name string, handler HandlerFunc) Client
+	InsertBefore(target, name string, handler HandlerFunc) error
+	InsertAfter(target, name string, handler HandlerFunc) error
+	Remove(name string) error
+	Handlers() []string
+	Stats() Stats
+	OnBeforeRequest(hook BeforeRequestHook) Client
+	OnAfterResponse(hook AfterResponseHook) Client
+	OnError(hook ErrorHook) Client
+	OnRetry(hook RetryHook) Client
 	Do(*Req) *Resp
 	New() *Req
 	Get(rawURL string) *Req
@@ -35,15 +49,30 @@ func NewClient(opts ...Option) Client {
 		return &Context{}
 	}
 
-	c.handlers = append(c.handlers, Recovery(), c.doHandler())
+	c.named = []namedHandler{
+		{name: HandlerNameRecovery, handler: Recovery()},
+		{name: HandlerNameDo, handler: c.doHandler()},
+	}
+	c.compile()
 	return c
 }
 
+type namedHandler struct {
+	name    string
+	handler HandlerFunc
+}
+
 type client struct {
 	options    Options
 	httpClient *http.Client
+	mu         sync.RWMutex
+	named      []namedHandler
 	handlers   HandlerChain
+	hooks      hooks
 	pool       sync.Pool
+	services   *services
+	dns        *resolvingDialer
+	conns      *connTracker
 }
 
 func (c *client) Init(opts ...Option) error {
@@ -51,7 +80,28 @@ func (c *client) Init(opts ...Option) error {
 		o(&c.options)
 	}
 	// init http client
-	c.httpClient = newHTTPClient(c.options)
+	dialer := &net.Dialer{
+		Timeout:   c.options.DialTimeout,
+		KeepAlive: c.options.DialKeepAlive,
+	}
+	dialContext := DialContext(dialer.DialContext)
+	if c.options.DialContext != nil {
+		dialContext = c.options.DialContext
+	}
+	c.dns = newResolvingDialer(c.options, dialer, dialContext)
+	if c.dns != nil {
+		dialContext = c.dns.DialContext
+	}
+	dialContext = unixDialContext(c.options.UnixSocket, dialContext)
+	c.conns = newConnTracker(c.options.IdleReadTimeout)
+	dialContext = c.conns.dialContext(dialContext)
+	c.httpClient = newHTTPClient(c.options, dialContext)
+	// the pools of the previous options are closed, unless a clone still uses them
+	c.services.release()
+	c.services = nil
+	if c.options.Resolver != nil {
+		c.services = newServices(c.options)
+	}
 	return nil
 }
 
@@ -59,26 +109,157 @@ func (c *client) Options() Options {
 	return c.options
 }
 
+// Use appends handlers just before doHandler, named after their functions.
+// The closures of the same function share its name, like goreq.LogHandler.func1,
+// so the following ones are suffixed with their rank, like goreq.LogHandler.func1#2.
 func (c *client) Use(handlers ...HandlerFunc) Client {
-	finalSize := len(c.handlers) + len(handlers)
-	mergedHandlers := make(HandlerChain, finalSize)
-	copy(mergedHandlers, c.handlers[:len(c.handlers)-1])
-	copy(mergedHandlers[len(c.handlers)-1:finalSize-1], handlers)
-	copy(mergedHandlers[finalSize-1:], c.handlers[len(c.handlers)-1:])
-	c.handlers = mergedHandlers
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range handlers {
+		c.named = insertHandler(c.named, len(c.named)-1, namedHandler{name: c.uniqueName(nameOfFunction(h)), handler: h})
+	}
+	c.compile()
 	return c
 }
 
+// UseNamed appends handler just before doHandler,
+// a handler with the same name is replaced in place. The reserved name of doHandler is refused, handler is not added.
+func (c *client) UseNamed(name string, handler HandlerFunc) Client {
+	if name == HandlerNameDo {
+		return c
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.indexOf(name); i >= 0 {
+		c.named[i].handler = handler
+	} else {
+		c.named = insertHandler(c.named, len(c.named)-1, namedHandler{name: name, handler: handler})
+	}
+	c.compile()
+	return c
+}
+
+// InsertBefore inserts handler before the target handler,
+// a handler with the same name is moved to the new position.
+func (c *client) InsertBefore(target, name string, handler HandlerFunc) error {
+	return c.insert(target, name, handler, 0)
+}
+
+// InsertAfter inserts handler after the target handler,
+// a handler with the same name is moved to the new position.
+func (c *client) InsertAfter(target, name string, handler HandlerFunc) error {
+	if target == HandlerNameDo {
+		return ErrHandlerReserved
+	}
+	return c.insert(target, name, handler, 1)
+}
+
+func (c *client) insert(target, name string, handler HandlerFunc, offset int) error {
+	if name == HandlerNameDo {
+		return ErrHandlerReserved
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.indexOf(target)
+	if t < 0 {
+		return ErrHandlerNotFound
+	}
+	if name == target {
+		c.named[t].handler = handler
+		c.compile()
+		return nil
+	}
+	if i := c.indexOf(name); i >= 0 {
+		c.named = append(c.named[:i:i], c.named[i+1:]...)
+	}
+	c.named = insertHandler(c.named, c.indexOf(target)+offset, namedHandler{name: name, handler: handler})
+	c.compile()
+	return nil
+}
+
+// Remove removes the first handler with the given name.
+func (c *client) Remove(name string) error {
+	if name == HandlerNameDo {
+		return ErrHandlerReserved
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.indexOf(name)
+	if i < 0 {
+		return ErrHandlerNotFound
+	}
+	c.named = append(c.named[:i:i], c.named[i+1:]...)
+	c.compile()
+	return nil
+}
+
+// Handlers returns the names of the handlers in the chain, in calling order.
+func (c *client) Handlers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, len(c.named))
+	for i, h := range c.named {
+		names[i] = h.name
+	}
+	return names
+}
+
+// Stats returns the counters of the client's transport
+func (c *client) Stats() Stats {
+	return Stats{
+		DNS:   c.dns.Stats(),
+		Hosts: c.conns.stats(),
+	}
+}
+
+// uniqueName returns name, suffixed with #2, #3... when a handler already has it
+func (c *client) uniqueName(name string) string {
+	unique := name
+	for i := 2; c.indexOf(unique) >= 0; i++ {
+		unique = name + "#" + strconv.Itoa(i)
+	}
+	return unique
+}
+
+func (c *client) indexOf(name string) int {
+	for i, h := range c.named {
+		if h.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// compile rebuilds the handler chain used by Do, the caller must hold c.mu.
+func (c *client) compile() {
+	handlers := make(HandlerChain, len(c.named))
+	for i, h := range c.named {
+		handlers[i] = h.handler
+	}
+	c.handlers = handlers
+}
+
 func (c *client) Clone(opts ...Option) Client {
 	c2 := &client{
 		options: c.Options(),
 	}
 	_ = c2.Init(opts...)
+	if len(opts) == 0 && c.services != nil {
+		// with the same options, the clone shares the endpoint pools and their health checks
+		c2.services.release()
+		c2.services = c.services.acquire()
+	}
 	c2.pool.New = func() interface{} {
 		return &Context{}
 	}
-	c2.handlers = make(HandlerChain, len(c.handlers))
-	copy(c2.handlers, c.handlers)
+	c.mu.RLock()
+	c2.named = make([]namedHandler, len(c.named))
+	copy(c2.named, c.named)
+	c2.hooks = c.hooks.clone()
+	c.mu.RUnlock()
+	// doHandler is bound to its client, so the clone needs its own one
+	c2.named[len(c2.named)-1].handler = c2.doHandler()
+	c2.compile()
 	return c2
 }
 
@@ -88,11 +269,33 @@ func (c *client) Do(r *Req) *Resp {
 	defer c.pool.Put(ctx)
 	ctx.Req = r
 	ctx.Resp = &Resp{}
-	ctx.handlers = c.handlers
+	ctx.handlers = c.combineHandlers(r.handlers)
+	c.mu.RLock()
+	ctx.hooks = c.hooks
+	c.mu.RUnlock()
 	ctx.Next()
+	if err := ctx.Resp.Error(); err != nil {
+		ctx.hooks.runError(r, ctx.Resp, err)
+		r.hooks.runError(r, ctx.Resp, err)
+	}
 	return ctx.Resp
 }
 
+// combineHandlers splices request scoped handlers in front of doHandler.
+func (c *client) combineHandlers(handlers HandlerChain) HandlerChain {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(handlers) == 0 {
+		return c.handlers
+	}
+	finalSize := len(c.handlers) + len(handlers)
+	mergedHandlers := make(HandlerChain, finalSize)
+	copy(mergedHandlers, c.handlers[:len(c.handlers)-1])
+	copy(mergedHandlers[len(c.handlers)-1:finalSize-1], handlers)
+	copy(mergedHandlers[finalSize-1:], c.handlers[len(c.handlers)-1:])
+	return mergedHandlers
+}
+
 func (c *client) New() *Req {
 	return New().WithClient(c)
 }
@@ -117,26 +320,51 @@ func (c *client) Head(rawURL string) *Req {
 	return c.New().WithURL(rawURL).WithMethod(http.MethodHead)
 }
 
-func newHTTPClient(options Options) *http.Client {
+func insertHandler(named []namedHandler, i int, h namedHandler) []namedHandler {
+	named = append(named, namedHandler{})
+	copy(named[i+1:], named[i:])
+	named[i] = h
+	return named
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+func newHTTPClient(options Options, dialContext DialContext) *http.Client {
 	var jar *cookiejar.Jar
 	if options.EnableCookie {
 		jar, _ = cookiejar.New(nil)
 	}
 	transport := options.Transport
 	if transport == nil {
-		dialer := &net.Dialer{
-			Timeout:   options.DialTimeout,
-			KeepAlive: options.DialKeepAlive,
-			//DualStack: true,
+		proxy := transportProxy(options.Proxy)
+		if options.UnixSocket != "" {
+			proxy = nil
+		}
+		t := &http.Transport{
+			Proxy:                  proxy,
+			ProxyConnectHeader:     options.ProxyConnectHeader,
+			DialContext:            dialContext,
+			MaxIdleConns:           options.MaxIdleConns,
+			MaxIdleConnsPerHost:    options.MaxIdleConnsPerHost,
+			MaxConnsPerHost:        options.MaxConnsPerHost,
+			ResponseHeaderTimeout:  options.ResponseHeaderTimeout,
+			IdleConnTimeout:        options.IdleConnTimeout,
+			TLSHandshakeTimeout:    options.TLSHandshakeTimeout,
+			TLSClientConfig:        options.TLSClientConfig,
+			ExpectContinueTimeout:  options.ExpectContinueTimeout,
+			ForceAttemptHTTP2:      options.ForceHTTP2,
+			MaxResponseHeaderBytes: options.MaxHeaderListSize,
 		}
-		transport = &http.Transport{
-			Proxy:                 http.ProxyFromEnvironment,
-			DialContext:           dialer.DialContext,
-			MaxIdleConns:          options.MaxIdleConns,
-			IdleConnTimeout:       options.IdleConnTimeout,
-			TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
-			TLSClientConfig:       options.TLSClientConfig,
-			ExpectContinueTimeout: options.ExpectContinueTimeout,
+		configureHTTP2(t, options)
+		transport = withHostPolicies(t, options.HostPolicies)
+		if options.H2C {
+			// the prior knowledge needs a transport without HTTP/1, which the other urls keep
+			h2c := t.Clone()
+			h2c.Protocols = new(http.Protocols)
+			h2c.Protocols.SetUnencryptedHTTP2(true)
+			transport = newH2CTransport(transport, withHostPolicies(h2c, options.HostPolicies), dialContext)
 		}
 	}
 	return &http.Client{
@@ -146,25 +374,119 @@ func newHTTPClient(options Options) *http.Client {
 	}
 }
 
+// endpoints returns the pool balancing r, the pool of its service for service urls,
+// the client's endpoints for relative urls, or nil
+func (c *client) endpoints(r *Req) (*EndpointPool, error) {
+	if service, _, ok := splitServiceURL(r.rawURL); ok {
+		if c.services == nil {
+			return nil, ErrNoResolver
+		}
+		return c.services.pool(r.Context(), service)
+	}
+	if r.isAbsolute() {
+		return nil, nil
+	}
+	return c.options.Endpoints, nil
+}
+
+func withHostPolicies(t *http.Transport, policies map[string]HostPolicy) http.RoundTripper {
+	if len(policies) == 0 {
+		return t
+	}
+	return newHostTransport(t, policies)
+}
+
+// configureHTTP2 sets the protocols and the HTTP/2 settings of t
+func configureHTTP2(t *http.Transport, options Options) {
+	switch {
+	case options.DisableHTTP2:
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	case options.H2C:
+		// the https urls negotiate HTTP/2, or keep HTTP/1 with the servers without it
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+		t.Protocols.SetHTTP2(true)
+	}
+	if options.HTTP2ReadIdleTimeout > 0 || options.HTTP2PingTimeout > 0 {
+		t.HTTP2 = &http.HTTP2Config{
+			SendPingTimeout: options.HTTP2ReadIdleTimeout,
+			PingTimeout:     options.HTTP2PingTimeout,
+		}
+	}
+}
+
 func (c *client) doHandler() HandlerFunc {
 	return func(ctx *Context) {
 		if ctx.Req.Error() != nil {
 			ctx.Resp.SetError(ctx.Req.Error())
 			return
 		}
-		request, err := ctx.Req.Build()
+		if err := ctx.hooks.runBeforeRequest(ctx.Req); err != nil {
+			ctx.Resp.SetError(err)
+			return
+		}
+		if err := ctx.Req.hooks.runBeforeRequest(ctx.Req); err != nil {
+			ctx.Resp.SetError(err)
+			return
+		}
+		pool, err := c.endpoints(ctx.Req)
+		if err != nil {
+			ctx.Resp.SetError(err)
+			return
+		}
+		var endpoint *EndpointState
+		var base string
+		if pool != nil {
+			if endpoint, err = pool.Pick(ctx.Req); err != nil {
+				ctx.Resp.SetError(err)
+				return
+			}
+			base = endpoint.URL
+		}
+		// the endpoint is not kept on the request, so that a reused request picks its own one
+		request, err := ctx.Req.build(base)
 		if err != nil {
 			ctx.Resp.SetError(err)
 			return
 		}
+		var proxy *proxyState
+		if proxyPool := c.options.ProxyPool; proxyPool != nil && ctx.Req.proxy == nil {
+			proxy = proxyPool.pick()
+			request = request.WithContext(context.WithValue(request.Context(), proxyContextKey{}, proxy.url))
+		}
 		ctx.Resp.request = request
 		before := time.Now()
+		if endpoint != nil {
+			pool.acquire(endpoint)
+		}
+		done := func(*http.Response, error) {}
+		if c.options.Transport == nil {
+			request, done = c.conns.trace(request)
+		}
 		ctx.Resp.response, ctx.Resp.err = c.httpClient.Do(request)
+		done(ctx.Resp.response, ctx.Resp.err)
+		if endpoint != nil {
+			pool.release(endpoint, ctx.Resp)
+		}
+		if proxy != nil {
+			c.options.ProxyPool.release(proxy, ctx.Resp)
+		}
 		ctx.Resp.codecs = c.Options().Codecs
 		ctx.Resp.cost = time.Since(before)
 
 		if ctx.Resp.err != nil && strings.Contains(ctx.Resp.err.Error(), "Client.Timeout exceeded") { // 超时的判断
 			ctx.Resp.timeout = true
 		}
+		if ctx.Resp.err != nil {
+			return
+		}
+		if err := ctx.hooks.runAfterResponse(ctx.Resp); err != nil {
+			ctx.Resp.SetError(err)
+			return
+		}
+		if err := ctx.Req.hooks.runAfterResponse(ctx.Resp); err != nil {
+			ctx.Resp.SetError(err)
+		}
 	}
 }
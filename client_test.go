@@ -0,0 +1,259 @@
+package goreq
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReqUse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		}
+		w.Header().Set("X-Scoped", r.Header.Get("X-Scoped"))
+	}))
+	defer ts.Close()
+
+	c := NewClient()
+	var calls []string
+	c.Use(func(ctx *Context) {
+		calls = append(calls, "client")
+	})
+	scoped := func(ctx *Context) {
+		calls = append(calls, "req")
+		ctx.Req.WithHeader("X-Scoped", "yes")
+	}
+
+	resp := c.Get(ts.URL).Use(scoped).Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	if got := resp.Response().Header.Get("X-Scoped"); got != "yes" {
+		t.Fatalf("scoped handler not applied, got %q", got)
+	}
+	if len(calls) != 2 || calls[0] != "client" || calls[1] != "req" {
+		t.Fatalf("unexpected handler order: %v", calls)
+	}
+
+	// the cookie set by the first request must be sent by the second one,
+	// which only works if both requests share the client's cookie jar.
+	resp = c.Get(ts.URL).Use(scoped).Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	if len(resp.Response().Cookies()) != 0 {
+		t.Fatal("request scoped handlers should share the client's cookie jar")
+	}
+
+	calls = nil
+	c.Get(ts.URL).Do()
+	if len(calls) != 1 {
+		t.Fatalf("request scoped handler leaked into client: %v", calls)
+	}
+}
+
+func TestClientNamedHandlers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var calls []string
+	record := func(name string) HandlerFunc {
+		return func(ctx *Context) {
+			calls = append(calls, name)
+		}
+	}
+	c := NewClient()
+	c.UseNamed("log", record("log"))
+	c.UseNamed("retry", record("retry"))
+	if err := c.InsertAfter("retry", "auth", record("auth")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InsertBefore("log", "decompress", record("decompress")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{HandlerNameRecovery, "decompress", "log", "retry", "auth", HandlerNameDo}
+	if got := c.Handlers(); !equalStrings(got, want) {
+		t.Fatalf("handlers = %v, want %v", got, want)
+	}
+
+	// moving an existing handler
+	if err := c.InsertBefore("decompress", "auth", record("auth")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove("log"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{HandlerNameRecovery, "auth", "decompress", "retry", HandlerNameDo}
+	if got := c.Handlers(); !equalStrings(got, want) {
+		t.Fatalf("handlers = %v, want %v", got, want)
+	}
+
+	if err := c.Remove("missing"); err != ErrHandlerNotFound {
+		t.Fatalf("Remove(missing) = %v", err)
+	}
+	if err := c.Remove(HandlerNameDo); err != ErrHandlerReserved {
+		t.Fatalf("Remove(do) = %v", err)
+	}
+	if err := c.InsertAfter(HandlerNameDo, "late", record("late")); err != ErrHandlerReserved {
+		t.Fatalf("InsertAfter(do) = %v", err)
+	}
+	if got := c.UseNamed(HandlerNameDo, record("do")).Handlers(); !equalStrings(got, want) {
+		t.Fatalf("UseNamed(do) added a handler: %v", got)
+	}
+
+	c.Get(ts.URL).Do()
+	if want := []string{"auth", "decompress", "retry"}; !equalStrings(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestClientUseSameFunction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var calls []string
+	record := func(name string) HandlerFunc {
+		return func(ctx *Context) {
+			calls = append(calls, name)
+		}
+	}
+	c := NewClient()
+	c.Use(record("a"), record("b"))
+	c.Use(record("c"))
+	name := nameOfFunction(record(""))
+	want := []string{HandlerNameRecovery, name, name + "#2", name + "#3", HandlerNameDo}
+	if got := c.Handlers(); !equalStrings(got, want) {
+		t.Fatalf("handlers = %v, want %v", got, want)
+	}
+	if err := c.Remove(name + "#2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InsertBefore(name, "d", record("d")); err != nil {
+		t.Fatal(err)
+	}
+	// the name of a removed handler is reused
+	c.Use(record("e"))
+	want = []string{HandlerNameRecovery, "d", name, name + "#3", name + "#2", HandlerNameDo}
+	if got := c.Handlers(); !equalStrings(got, want) {
+		t.Fatalf("handlers = %v, want %v", got, want)
+	}
+
+	c.Get(ts.URL).Do()
+	if want := []string{"d", "a", "c", "e"}; !equalStrings(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestClientHooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Hook")))
+	}))
+	defer ts.Close()
+
+	errDenied := errors.New("denied")
+	var errs []error
+	c := NewClient().
+		OnBeforeRequest(func(r *Req) error {
+			r.WithHeader("X-Hook", "client")
+			return nil
+		}).
+		OnError(func(r *Req, resp *Resp, err error) {
+			errs = append(errs, err)
+		})
+
+	resp := c.Get(ts.URL).Do()
+	if got := resp.String(); got != "client" {
+		t.Fatalf("body = %q", got)
+	}
+
+	resp = c.Get(ts.URL).OnAfterResponse(func(resp *Resp) error {
+		return errDenied
+	}).Do()
+	if !errors.Is(resp.Error(), errDenied) {
+		t.Fatalf("error = %v", resp.Error())
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], errDenied) {
+		t.Fatalf("error hooks got %v", errs)
+	}
+}
+
+func TestRetryHooks(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var retries []string
+	c := NewClient().OnRetry(func(r *Req, resp *Resp, attempt int) {
+		retries = append(retries, strconv.Itoa(attempt)+":"+strconv.Itoa(resp.StatusCode()))
+	})
+	c.Use(RetryHandler(3, time.Millisecond))
+	resp := c.Get(ts.URL).OnRetry(func(r *Req, resp *Resp, attempt int) {
+		retries = append(retries, "req")
+	}).Do()
+	if got := resp.String(); got != "ok" || hits != 3 {
+		t.Fatalf("body = %q after %d hits", got, hits)
+	}
+	if want := []string{"1:503", "req", "2:503", "req"}; !equalStrings(retries, want) {
+		t.Fatalf("retries = %v, want %v", retries, want)
+	}
+
+	// requests which are not idempotent are sent once
+	hits, retries = 0, nil
+	if resp = c.Post(ts.URL).Do(); resp.StatusCode() != http.StatusServiceUnavailable || hits != 1 || len(retries) != 0 {
+		t.Fatalf("status = %d after %d hits and retries %v", resp.StatusCode(), hits, retries)
+	}
+}
+
+func TestRetryUploads(t *testing.T) {
+	var hits int
+	var files []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		files = append(files, string(data))
+		if hits == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient()
+	c.Use(RetryHandler(1, 0))
+	resp := c.Put(ts.URL).AddFile("file", "notes.txt", io.NopCloser(strings.NewReader("notes"))).Do()
+	if resp.StatusCode() != http.StatusOK || hits != 2 {
+		t.Fatalf("status = %d after %d hits", resp.StatusCode(), hits)
+	}
+	if want := []string{"notes", "notes"}; !equalStrings(files, want) {
+		t.Fatalf("files = %q, want %q", files, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -10,12 +10,14 @@ type Context struct {
 	index    int8
 	handlers HandlerChain
 	err      error
+	hooks    hooks
 	Req      *Req
 	Resp     *Resp
 }
 
 func (c *Context) reset() {
 	c.index = -1
+	c.hooks = hooks{}
 	c.Req = nil
 	c.Resp = nil
 }
@@ -40,6 +42,25 @@ func (c *Context) Abort() {
 	c.index = abortIndex
 }
 
+// Retrying runs the retry hooks of the client and the request,
+// retry handlers should call it before sending attempt again.
+func (c *Context) Retrying(attempt int) {
+	c.hooks.runRetry(c.Req, c.Resp, attempt)
+	c.Req.hooks.runRetry(c.Req, c.Resp, attempt)
+}
+
+// Copy returns a context to run the pending handlers again, on a clone of the request and a new response.
+// Handlers like hedging use it to send several attempts of a request concurrently.
+func (c *Context) Copy() *Context {
+	return &Context{
+		index:    c.index,
+		handlers: c.handlers,
+		hooks:    c.hooks,
+		Req:      c.Req.Clone(),
+		Resp:     &Resp{},
+	}
+}
+
 func (c *Context) AbortWithError(err error) {
 	c.err = err
 	c.Abort()
@@ -0,0 +1,505 @@
+package goreq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const contentTypeCurlForm = "application/x-www-form-urlencoded"
+
+// AsCurl returns a curl command sending the same request. The readers of the uploaded files are not read,
+// they are referenced by their file names, as -F field=@name, so the command only sends the same files
+// when they exist under these names in the working directory. Resp.AsCurl renders the body which was sent.
+func (r *Req) AsCurl() string {
+	args := []string{"curl"}
+	multipartForm := len(r.uploads) > 0 && (r.method == http.MethodPost || r.method == http.MethodPut)
+	switch r.method {
+	case http.MethodGet:
+		// curl sends a body with POST unless told otherwise
+		if multipartForm || len(r.formParams) > 0 || len(r.body) > 0 {
+			args = append(args, "-X", r.method)
+		}
+	case http.MethodHead:
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X", r.method)
+	}
+	for _, key := range sortedKeys(r.header) {
+		if multipartForm && key == ContentType {
+			continue
+		}
+		for _, value := range r.header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+	if len(r.cookies) > 0 {
+		cookies := make([]string, len(r.cookies))
+		for i, c := range r.cookies {
+			cookies[i] = c.Name + "=" + c.Value
+		}
+		args = append(args, "-b", shellQuote(strings.Join(cookies, "; ")))
+	}
+	switch {
+	case multipartForm:
+		for _, key := range sortedKeys(r.formParams) {
+			for _, value := range r.formParams[key] {
+				args = append(args, "--form-string", shellQuote(key+"="+value))
+			}
+		}
+		for i, upload := range r.uploads {
+			if upload.FieldName == "" {
+				upload.FieldName = "file" + strconv.Itoa(i)
+			}
+			args = append(args, "-F", shellQuote(upload.FieldName+"=@"+upload.FileName))
+		}
+	case len(r.formParams) > 0:
+		if r.header.Get(ContentType) == "" {
+			args = append(args, "-H", shellQuote(ContentType+": "+ContentTypeForm))
+		}
+		args = append(args, "--data-raw", shellQuote(r.formParams.Encode()))
+	case len(r.body) > 0:
+		args = append(args, "--data-binary", shellQuote(string(r.body)))
+	}
+	args = append(args, shellQuote(r.appendQuery(r.baseURL(""))))
+	return strings.Join(args, " ")
+}
+
+// AsCurl returns a curl command sending the request which was actually sent
+func (r *Resp) AsCurl() string {
+	request := r.Request()
+	if request == nil {
+		return ""
+	}
+	var data []byte
+	if request.GetBody != nil {
+		if body, err := request.GetBody(); err == nil {
+			data, _ = io.ReadAll(body)
+			_ = body.Close()
+		}
+	}
+	args := []string{"curl"}
+	switch request.Method {
+	case http.MethodGet, "":
+		if len(data) > 0 {
+			args = append(args, "-X", http.MethodGet)
+		}
+	case http.MethodHead:
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X", request.Method)
+	}
+	if request.Host != "" && request.Host != request.URL.Host && request.Header.Get("Host") == "" {
+		args = append(args, "-H", shellQuote("Host: "+request.Host))
+	}
+	for _, key := range sortedKeys(request.Header) {
+		for _, value := range request.Header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+	if len(data) > 0 {
+		args = append(args, "--data-binary", shellQuote(string(data)))
+	}
+	args = append(args, shellQuote(request.URL.String()))
+	return strings.Join(args, " ")
+}
+
+// FromCurl parses a curl command into a request of DefaultClient.
+// Supported flags are -X, -H, -d, --data-raw, --data-binary, --data-urlencode, -F, --form-string,
+// -u, -b, -A, -e, -G, -I and --url, flags only affecting curl's own output are ignored.
+func FromCurl(cmd string) (*Req, error) {
+	args, err := splitCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, fmt.Errorf("%w: not a curl command", ErrInvalidCurl)
+	}
+	p := &curlParser{req: New()}
+	if err = p.parse(args[1:]); err != nil {
+		return nil, err
+	}
+	return p.build()
+}
+
+// curl flags taking a value
+var curlValueFlags = map[string]string{
+	"-X": "-X", "--request": "-X",
+	"-H": "-H", "--header": "-H",
+	"-d": "-d", "--data": "-d", "--data-ascii": "-d",
+	"--data-binary": "--data-binary", "--data-raw": "--data-raw", "--data-urlencode": "--data-urlencode",
+	"-F": "-F", "--form": "-F", "--form-string": "--form-string",
+	"-u": "-u", "--user": "-u",
+	"-b": "-b", "--cookie": "-b",
+	"-A": "-A", "--user-agent": "-A",
+	"-e": "-e", "--referer": "-e",
+	"--url": "--url",
+	// ignored
+	"-o": "", "--output": "", "-m": "", "--max-time": "", "--connect-timeout": "", "-w": "", "--write-out": "",
+}
+
+// curl flags without value
+var curlBoolFlags = map[string]string{
+	"-G": "-G", "--get": "-G",
+	"-I": "-I", "--head": "-I",
+	// ignored
+	"-s": "", "--silent": "", "-S": "", "--show-error": "", "-L": "", "--location": "",
+	"-k": "", "--insecure": "", "-v": "", "--verbose": "", "-i": "", "--include": "",
+	"--compressed": "", "-f": "", "--fail": "", "-N": "", "--no-buffer": "", "--http1.1": "", "--http2": "",
+}
+
+type curlParser struct {
+	req     *Req
+	method  string
+	rawURL  string
+	get     bool
+	data    []string
+	form    *multipart.Writer
+	formBuf *bytes.Buffer
+}
+
+func (p *curlParser) parse(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			p.rawURL = arg
+			continue
+		}
+		flag, value, hasValue := arg, "", false
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			// combined short flags, like -sSL or -XPOST
+			j := 1
+			for ; j < len(arg); j++ {
+				f := "-" + arg[j:j+1]
+				if _, ok := curlBoolFlags[f]; ok {
+					if err := p.apply(curlBoolFlags[f], ""); err != nil {
+						return err
+					}
+					continue
+				}
+				if _, ok := curlValueFlags[f]; !ok {
+					return fmt.Errorf("%w: unsupported flag %s", ErrInvalidCurl, f)
+				}
+				break
+			}
+			if j == len(arg) {
+				continue
+			}
+			flag = "-" + arg[j:j+1]
+			if j+1 < len(arg) {
+				value, hasValue = arg[j+1:], true
+			}
+		}
+		if name, ok := curlBoolFlags[flag]; ok {
+			if err := p.apply(name, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		name, ok := curlValueFlags[flag]
+		if !ok {
+			return fmt.Errorf("%w: unsupported flag %s", ErrInvalidCurl, flag)
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("%w: flag %s needs a value", ErrInvalidCurl, flag)
+			}
+			i++
+			value = args[i]
+		}
+		if err := p.apply(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *curlParser) apply(flag, value string) error {
+	switch flag {
+	case "-X":
+		p.method = strings.ToUpper(value)
+	case "-G":
+		p.get = true
+	case "-I":
+		if p.method == "" {
+			p.method = http.MethodHead
+		}
+	case "-H":
+		key, val, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("%w: invalid header %q", ErrInvalidCurl, value)
+		}
+		if val = strings.TrimSpace(val); val != "" {
+			p.req.AddHeader(strings.TrimSpace(key), val)
+		}
+	case "-d", "--data-binary":
+		if strings.HasPrefix(value, "@") {
+			data, err := os.ReadFile(value[1:])
+			if err != nil {
+				return err
+			}
+			if value = string(data); flag == "-d" {
+				value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+			}
+		}
+		p.data = append(p.data, value)
+	case "--data-raw":
+		p.data = append(p.data, value)
+	case "--data-urlencode":
+		data, err := curlURLEncode(value)
+		if err != nil {
+			return err
+		}
+		p.data = append(p.data, data)
+	case "-F", "--form-string":
+		return p.addFormField(value, flag == "-F")
+	case "-u":
+		username, password, _ := strings.Cut(value, ":")
+		p.req.WithBasicAuth(username, password)
+	case "-b":
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("%w: cookie files are not supported", ErrInvalidCurl)
+		}
+		for _, pair := range strings.Split(value, ";") {
+			name, val, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if name != "" {
+				p.req.AddCookie(&http.Cookie{Name: name, Value: val})
+			}
+		}
+	case "-A":
+		p.req.WithUserAgent(value)
+	case "-e":
+		p.req.WithReferer(value)
+	case "--url":
+		p.rawURL = value
+	}
+	return nil
+}
+
+func (p *curlParser) addFormField(value string, special bool) error {
+	if p.form == nil {
+		p.formBuf = new(bytes.Buffer)
+		p.form = multipart.NewWriter(p.formBuf)
+	}
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("%w: invalid form field %q", ErrInvalidCurl, value)
+	}
+	if !special || (!strings.HasPrefix(content, "@") && !strings.HasPrefix(content, "<")) {
+		return p.form.WriteField(name, content)
+	}
+	path, params, _ := strings.Cut(content[1:], ";")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if content[0] == '<' {
+		return p.form.WriteField(name, string(data))
+	}
+	fileName := path[strings.LastIndexAny(path, `/\`)+1:]
+	for _, param := range strings.Split(params, ";") {
+		if strings.HasPrefix(param, "filename=") {
+			fileName = strings.Trim(strings.TrimPrefix(param, "filename="), `"`)
+		}
+	}
+	w, err := p.form.CreateFormFile(name, fileName)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (p *curlParser) build() (*Req, error) {
+	if p.rawURL == "" {
+		return nil, ErrNoURL
+	}
+	if !strings.Contains(p.rawURL, "://") {
+		p.rawURL = "http://" + p.rawURL
+	}
+	r := p.req
+	method := http.MethodGet
+	switch {
+	case p.form != nil:
+		if err := p.form.Close(); err != nil {
+			return nil, err
+		}
+		method = http.MethodPost
+		r.WithContentType(p.form.FormDataContentType())
+		r.WithBinaryBody(p.formBuf.Bytes())
+	case len(p.data) > 0 && p.get:
+		sep := "?"
+		if strings.Contains(p.rawURL, "?") {
+			sep = "&"
+		}
+		p.rawURL += sep + strings.Join(p.data, "&")
+	case len(p.data) > 0:
+		method = http.MethodPost
+		if r.header.Get(ContentType) == "" {
+			r.WithContentType(contentTypeCurlForm)
+		}
+		r.WithBinaryBody([]byte(strings.Join(p.data, "&")))
+	}
+	if p.method != "" {
+		method = p.method
+	}
+	return r.WithURL(p.rawURL).WithMethod(method), nil
+}
+
+// curlURLEncode encodes a --data-urlencode value the way curl does
+func curlURLEncode(value string) (string, error) {
+	readFile := func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+	if i := strings.IndexAny(value, "=@"); i >= 0 {
+		name, content := value[:i], value[i+1:]
+		if value[i] == '@' {
+			var err error
+			if content, err = readFile(content); err != nil {
+				return "", err
+			}
+		}
+		if name == "" {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+	return url.QueryEscape(value), nil
+}
+
+// shellQuote quotes s for POSIX shells if needed
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:@%+,=", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitCommand splits a shell command line into arguments,
+// supporting quotes, escapes and line continuations.
+func splitCommand(cmd string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		runes   = []rune(cmd)
+		unclose = fmt.Errorf("%w: unclosed quote", ErrInvalidCurl)
+	)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					cur.WriteRune(runes[i])
+					inArg = true
+				}
+			}
+		case c == '\'':
+			inArg = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, unclose
+			}
+			cur.WriteString(string(runes[i+1 : end]))
+			i = end
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			inArg = true
+			for i += 2; ; i++ {
+				if i >= len(runes) {
+					return nil, unclose
+				}
+				if runes[i] == '\'' {
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					cur.WriteRune(ansiEscape(runes[i]))
+					continue
+				}
+				cur.WriteRune(runes[i])
+			}
+		case c == '"':
+			inArg = true
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, unclose
+				}
+				if runes[i] == '"' {
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				cur.WriteRune(runes[i])
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func ansiEscape(c rune) rune {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
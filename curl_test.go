@@ -0,0 +1,130 @@
+package goreq
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFromCurl(t *testing.T) {
+	r, err := FromCurl(`curl -sSL -X PUT 'https://example.com/api?x=1' \
+  -H 'Content-Type: application/json' -H "X-Quote: it's \"ok\"" \
+  -u alice:secret -b 'a=1; b=2' --data-raw '{"name":"bob"}'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetMethod() != http.MethodPut || r.GetURL() != "https://example.com/api?x=1" {
+		t.Fatalf("method %s url %s", r.GetMethod(), r.GetURL())
+	}
+	if got := r.GetHeader().Get("X-Quote"); got != `it's "ok"` {
+		t.Fatalf("X-Quote = %q", got)
+	}
+	if !strings.HasPrefix(r.GetHeader().Get("Authorization"), "Basic ") {
+		t.Fatal("missing basic auth")
+	}
+	if len(r.cookies) != 2 || r.cookies[1].Name != "b" || r.cookies[1].Value != "2" {
+		t.Fatalf("cookies = %v", r.cookies)
+	}
+	if string(r.GetBody()) != `{"name":"bob"}` {
+		t.Fatalf("body = %s", r.GetBody())
+	}
+
+	r, err = FromCurl(`curl example.com/search -G -d q=a --data-urlencode 'name=b c'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetMethod() != http.MethodGet || r.GetURL() != "http://example.com/search?q=a&name=b+c" {
+		t.Fatalf("method %s url %s", r.GetMethod(), r.GetURL())
+	}
+
+	r, err = FromCurl(`curl -F name=bob --form-string 'note=@literal' http://example.com/upload`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetMethod() != http.MethodPost || !strings.HasPrefix(r.GetHeader().Get(ContentType), "multipart/form-data") {
+		t.Fatalf("method %s content type %s", r.GetMethod(), r.GetHeader().Get(ContentType))
+	}
+	if !strings.Contains(string(r.GetBody()), "@literal") {
+		t.Fatalf("body = %s", r.GetBody())
+	}
+
+	if _, err = FromCurl(`curl --unknown http://example.com`); err == nil {
+		t.Fatal("expected error for unknown flag")
+	}
+	if _, err = FromCurl(`curl 'http://example.com`); err == nil {
+		t.Fatal("expected error for unclosed quote")
+	}
+}
+
+func TestAsCurlRoundTrip(t *testing.T) {
+	r := New().WithURL("http://example.com/items").WithMethod(http.MethodPost).
+		WithQueryParam("page", 2).
+		WithHeader("X-Note", "it's").
+		AddCookie(&http.Cookie{Name: "sid", Value: "1"}).
+		WithBody(`{"a":"b c"}`)
+	cmd := r.AsCurl()
+	want := `curl -X POST -H 'X-Note: it'\''s' -b sid=1 --data-binary '{"a":"b c"}' 'http://example.com/items?page=2'`
+	if cmd != want {
+		t.Fatalf("AsCurl() =\n%s\nwant\n%s", cmd, want)
+	}
+	r2, err := FromCurl(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.GetURL() != "http://example.com/items?page=2" || string(r2.GetBody()) != `{"a":"b c"}` ||
+		r2.GetHeader().Get("X-Note") != "it's" || r2.GetMethod() != http.MethodPost {
+		t.Fatalf("round trip mismatch: %s", r2.AsCurl())
+	}
+
+	// curl sends a body with POST unless the method is given
+	r = New().WithURL("http://example.com/search").WithFormParam("q", "a b")
+	cmd = r.AsCurl()
+	want = `curl -X GET -H 'Content-Type: application/x-www-form-urlencoded; charset=UTF-8' --data-raw q=a+b http://example.com/search`
+	if cmd != want {
+		t.Fatalf("AsCurl() =\n%s\nwant\n%s", cmd, want)
+	}
+	if r2, err = FromCurl(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if r2.GetMethod() != http.MethodGet || string(r2.GetBody()) != "q=a+b" {
+		t.Fatalf("round trip mismatch: %s", r2.AsCurl())
+	}
+	if cmd = r2.AsCurl(); !strings.HasPrefix(cmd, "curl -X GET ") {
+		t.Fatalf("expected the body of a GET to keep its method: %s", cmd)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	if cmd = New().WithURL(ts.URL).WithBody("{}").Do().AsCurl(); !strings.HasPrefix(cmd, "curl -X GET ") ||
+		!strings.Contains(cmd, "--data-binary '{}'") {
+		t.Fatalf("expected the body of a GET to keep its method: %s", cmd)
+	}
+}
+
+func TestAsCurlUploads(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	r := New().WithURL(ts.URL).WithMethod(http.MethodPost).WithFormParam("name", "a b").
+		AddFileContent("avatar", "me.png", []byte("png")).
+		AddFile("", "notes.txt", io.NopCloser(strings.NewReader("notes")))
+	cmd := r.AsCurl()
+	want := "curl -X POST --form-string 'name=a b' -F avatar=@me.png -F file1=@notes.txt " + ts.URL
+	if cmd != want {
+		t.Fatalf("AsCurl() =\n%s\nwant\n%s", cmd, want)
+	}
+
+	// the command of the response has the body which was sent
+	resp := r.Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	cmd = resp.AsCurl()
+	for _, s := range []string{"Content-Type: multipart/form-data; boundary=", `filename="me.png"`, "png", `name="file1"; filename="notes.txt"`, "notes"} {
+		if !strings.Contains(cmd, s) {
+			t.Errorf("expected %q in %s", s, cmd)
+		}
+	}
+}
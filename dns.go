@@ -0,0 +1,258 @@
+package goreq
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DNSStats counts the lookups of the client's resolver
+type DNSStats struct {
+	Hits      int64 // lookups answered by the cache
+	Misses    int64 // lookups sent to the dns servers
+	Overrides int64 // lookups answered by a host override
+	Errors    int64 // failed lookups
+}
+
+// resolvingDialer resolves the hosts itself before dialing, for host overrides, dns servers and the dns cache
+type resolvingDialer struct {
+	dial      DialContext
+	timeout   time.Duration
+	resolver  *net.Resolver
+	overrides map[string][]string
+	ttl       time.Duration
+	mu        sync.RWMutex
+	cache     map[string]dnsEntry
+	stats     DNSStats
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// newResolvingDialer returns nil when none of the dns options is set, so that the plain dialer is used
+func newResolvingDialer(options Options, dialer *net.Dialer, dial DialContext) *resolvingDialer {
+	if len(options.HostOverrides) == 0 && len(options.DNSServers) == 0 && options.DNSCacheTTL <= 0 {
+		return nil
+	}
+	d := &resolvingDialer{
+		dial:      dial,
+		timeout:   options.DialTimeout,
+		resolver:  net.DefaultResolver,
+		overrides: options.HostOverrides,
+		ttl:       options.DNSCacheTTL,
+		cache:     make(map[string]dnsEntry),
+	}
+	if len(options.DNSServers) > 0 {
+		d.resolver = dnsServersResolver(options.DNSServers, dialer)
+	}
+	return d
+}
+
+// dnsServersResolver queries the servers in turn, like 8.8.8.8:53, the port defaults to 53
+func dnsServersResolver(servers []string, dialer *net.Dialer) *net.Resolver {
+	var next uint64
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			server := servers[(atomic.AddUint64(&next, 1)-1)%uint64(len(servers))]
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(server, "53")
+			}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+func (d *resolvingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	// unix sockets are dialed by path, there is nothing to resolve
+	if strings.HasPrefix(network, "unix") {
+		return d.dial(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.dial(ctx, network, address)
+	}
+	addrs, err := d.lookup(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if d.timeout > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.timeout)
+			defer cancel()
+		}
+	}
+	primaries, fallbacks := partitionAddrs(addrs)
+	if len(fallbacks) == 0 {
+		return d.dialSerial(ctx, network, port, primaries)
+	}
+	return d.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// fallbackDelay is how long the addresses of the other family wait for the first ones, like net.Dialer
+const fallbackDelay = 300 * time.Millisecond
+
+// dialParallel races the addresses of both families like net.Dialer does (Happy Eyeballs, RFC 6555),
+// the fallbacks start once the primaries failed or after fallbackDelay
+func (d *resolvingDialer) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []string) (net.Conn, error) {
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result)
+	race := func(addrs []string, primary bool) {
+		conn, err := d.dialSerial(ctx, network, port, addrs)
+		select {
+		case results <- result{conn: conn, err: err, primary: primary}:
+		case <-ctx.Done():
+			// the other family won
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}
+	go race(primaries, true)
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+	fallback := timer.C
+	pending := 1
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-fallback:
+			fallback = nil
+			pending++
+			go race(fallbacks, false)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.conn, nil
+			}
+			if r.primary {
+				primaryErr = r.err
+			} else {
+				fallbackErr = r.err
+			}
+			if fallback != nil {
+				fallback = nil
+				pending++
+				go race(fallbacks, false)
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// dialSerial tries the addresses in turn, the remaining time is split between them like net.Dialer does,
+// so that an unreachable address does not use all of it. The first error is the most relevant one.
+func (d *resolvingDialer) dialSerial(ctx context.Context, network, port string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for i, addr := range addrs {
+		conn, err := d.dialPartial(ctx, network, net.JoinHostPort(addr, port), len(addrs)-i)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// minDialTimeout is the shortest share of the remaining time given to an address, like net.Dialer
+const minDialTimeout = 2 * time.Second
+
+func (d *resolvingDialer) dialPartial(ctx context.Context, network, address string, remaining int) (net.Conn, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return d.dial(ctx, network, address)
+	}
+	timeout := time.Until(deadline) / time.Duration(remaining)
+	if timeout < minDialTimeout {
+		timeout = minDialTimeout
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return d.dial(dialCtx, network, address)
+}
+
+// partitionAddrs splits addrs into the addresses of the family of the first one, and the others
+func partitionAddrs(addrs []string) (primaries, fallbacks []string) {
+	isIPv4 := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		return ip == nil || ip.To4() != nil
+	}
+	for _, addr := range addrs {
+		if isIPv4(addr) == isIPv4(addrs[0]) {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// lookup returns the addresses of host from the overrides, the cache, or the resolver
+func (d *resolvingDialer) lookup(ctx context.Context, host, port string) ([]string, error) {
+	if addrs, ok := d.overrides[net.JoinHostPort(host, port)]; ok {
+		atomic.AddInt64(&d.stats.Overrides, 1)
+		return addrs, nil
+	}
+	if addrs, ok := d.overrides[host]; ok {
+		atomic.AddInt64(&d.stats.Overrides, 1)
+		return addrs, nil
+	}
+	if d.ttl > 0 {
+		d.mu.RLock()
+		entry, ok := d.cache[host]
+		d.mu.RUnlock()
+		if ok && time.Now().Before(entry.expires) {
+			atomic.AddInt64(&d.stats.Hits, 1)
+			return entry.addrs, nil
+		}
+	}
+	atomic.AddInt64(&d.stats.Misses, 1)
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		atomic.AddInt64(&d.stats.Errors, 1)
+		return nil, err
+	}
+	if d.ttl > 0 {
+		d.mu.Lock()
+		d.cache[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+		d.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+func (d *resolvingDialer) Stats() DNSStats {
+	if d == nil {
+		return DNSStats{}
+	}
+	return DNSStats{
+		Hits:      atomic.LoadInt64(&d.stats.Hits),
+		Misses:    atomic.LoadInt64(&d.stats.Misses),
+		Overrides: atomic.LoadInt64(&d.stats.Overrides),
+		Errors:    atomic.LoadInt64(&d.stats.Errors),
+	}
+}
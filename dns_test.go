@@ -0,0 +1,181 @@
+package goreq
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostOverride(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	c := NewClient(WithHostOverride("staging.invalid:"+port, "127.0.0.1"))
+	if body := c.Get("http://staging.invalid:" + port).Do().String(); body != "staging.invalid:"+port {
+		t.Fatalf("expected the override to be dialed with the original host, got %q", body)
+	}
+	if stats := c.Stats().DNS; stats != (DNSStats{Overrides: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	c := NewClient(WithDNSCacheTTL(time.Minute))
+	for i := 0; i < 3; i++ {
+		// new connections, so that every request dials
+		if err := c.Get("http://localhost:"+port).WithHeader("Connection", "close").Do().Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := c.Stats().DNS; stats != (DNSStats{Hits: 2, Misses: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// dnsStub answers the A queries of any name with 127.0.0.1, and the other queries with no record
+type dnsStub struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	queries []string
+}
+
+func newDNSStub(t *testing.T) *dnsStub {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dnsStub{conn: conn}
+	t.Cleanup(func() { _ = conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *dnsStub) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 12 {
+			continue
+		}
+		// the question follows the 12 bytes header: labels, then the type and the class
+		var labels []string
+		end := 12
+		for end < n && buf[end] != 0 {
+			l := int(buf[end])
+			if end+1+l > n {
+				break
+			}
+			labels = append(labels, string(buf[end+1:end+1+l]))
+			end += 1 + l
+		}
+		end += 5
+		if end > n {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(buf[end-4:])
+		s.mu.Lock()
+		s.queries = append(s.queries, strings.Join(labels, "."))
+		s.mu.Unlock()
+
+		resp := append([]byte(nil), buf[:end]...)
+		binary.BigEndian.PutUint16(resp[2:], 0x8180) // response, recursion desired and available
+		binary.BigEndian.PutUint16(resp[6:], 0)      // answers
+		binary.BigEndian.PutUint16(resp[8:], 0)      // authorities
+		binary.BigEndian.PutUint16(resp[10:], 0)     // additionals
+		if qtype == 1 {
+			binary.BigEndian.PutUint16(resp[6:], 1)
+			// pointer to the question name, type A, class IN, ttl, address
+			resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+		}
+		_, _ = s.conn.WriteTo(resp, addr)
+	}
+}
+
+func (s *dnsStub) queried(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.queries {
+		if q == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDNSServers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	stub := newDNSStub(t)
+
+	c := NewClient(WithDNSServers(stub.conn.LocalAddr().String()))
+	resp := c.Get("http://api.goreq.test:" + port).Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	if body := resp.String(); body != "api.goreq.test:"+port {
+		t.Errorf("unexpected host %q", body)
+	}
+	if !stub.queried("api.goreq.test") {
+		t.Errorf("expected the custom server to be queried, got %v", stub.queries)
+	}
+	if stats := c.Stats().DNS; stats.Misses != 1 || stats.Errors != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestDialFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	// the addresses of the documentation prefixes never answer
+	var dialer net.Dialer
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "[2001:db8:") || strings.HasPrefix(address, "192.0.2.") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+	tests := []struct {
+		name  string
+		addrs []string
+		max   time.Duration
+	}{
+		// the IPv4 address starts after the fallback delay, without waiting for the dead IPv6 address
+		{"fallback", []string{"2001:db8::1", "127.0.0.1"}, time.Second},
+		// the first address gets its share of the dial timeout only
+		{"serial", []string{"192.0.2.1", "127.0.0.1"}, 3 * time.Second},
+	}
+	for _, test := range tests {
+		c := NewClient(WithDialContext(dial), WithHostOverride("eyeballs.test", test.addrs...), func(options *Options) {
+			options.DialTimeout = 4 * time.Second
+		})
+		begin := time.Now()
+		if err := c.Get("http://eyeballs.test:" + port).Do().Error(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if elapsed := time.Since(begin); elapsed > test.max {
+			t.Errorf("%s: expected the dial to move on to the next address, took %s", test.name, elapsed)
+		}
+	}
+}
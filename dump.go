@@ -0,0 +1,240 @@
+package goreq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// DumpOptions configure what LogHandler and DumpHandler output
+type DumpOptions struct {
+	Logger           Logger
+	RedactHeaders    []string // headers whose values are replaced, case insensitive
+	RedactFields     []string // json fields whose scalar values are replaced, case insensitive
+	MaxBodySize      int      // bodies are truncated to this size, no limit if <= 0
+	SkipContentTypes []string // media type prefixes whose bodies are not output
+	SampleRate       float64  // ratio of successful requests output, errors and 5xx responses are always output
+}
+
+type DumpOption func(*DumpOptions)
+
+func newDumpOptions(opts ...DumpOption) DumpOptions {
+	options := DumpOptions{
+		Logger:           slog.Default(),
+		RedactHeaders:    []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+		MaxBodySize:      4096,
+		SkipContentTypes: []string{"image/", "audio/", "video/", "font/", "text/event-stream", "application/octet-stream", "application/zip", "application/pdf"},
+		SampleRate:       1,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// DumpWithLogger set the logger used by LogHandlerWithOptions
+func DumpWithLogger(logger Logger) DumpOption {
+	return func(options *DumpOptions) {
+		options.Logger = logger
+	}
+}
+
+// DumpWithRedactHeaders add headers to redact
+func DumpWithRedactHeaders(headers ...string) DumpOption {
+	return func(options *DumpOptions) {
+		options.RedactHeaders = append(options.RedactHeaders, headers...)
+	}
+}
+
+// DumpWithRedactFields add json fields to redact
+func DumpWithRedactFields(fields ...string) DumpOption {
+	return func(options *DumpOptions) {
+		options.RedactFields = append(options.RedactFields, fields...)
+	}
+}
+
+// DumpWithMaxBodySize set max body size to output
+func DumpWithMaxBodySize(size int) DumpOption {
+	return func(options *DumpOptions) {
+		options.MaxBodySize = size
+	}
+}
+
+// DumpWithSkipContentTypes add content types whose bodies are not output
+func DumpWithSkipContentTypes(contentTypes ...string) DumpOption {
+	return func(options *DumpOptions) {
+		options.SkipContentTypes = append(options.SkipContentTypes, contentTypes...)
+	}
+}
+
+// DumpWithSampleRate set the ratio of successful requests to output, errors and 5xx responses are always output
+func DumpWithSampleRate(rate float64) DumpOption {
+	return func(options *DumpOptions) {
+		options.SampleRate = rate
+	}
+}
+
+type dumper struct {
+	options DumpOptions
+	headers map[string]bool
+	fields  *regexp.Regexp
+}
+
+func newDumper(opts ...DumpOption) *dumper {
+	d := &dumper{
+		options: newDumpOptions(opts...),
+		headers: make(map[string]bool),
+	}
+	for _, h := range d.options.RedactHeaders {
+		d.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if len(d.options.RedactFields) > 0 {
+		names := make([]string, len(d.options.RedactFields))
+		for i, f := range d.options.RedactFields {
+			names[i] = regexp.QuoteMeta(f)
+		}
+		d.fields = regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return d
+}
+
+// sampled reports whether the request should be output
+func (d *dumper) sampled(ctx *Context) bool {
+	if ctx.Resp.Error() != nil || ctx.Resp.Response() == nil || ctx.Resp.StatusCode() >= http.StatusInternalServerError {
+		return true
+	}
+	return d.options.SampleRate >= 1 || rand.Float64() < d.options.SampleRate
+}
+
+func (d *dumper) header(h http.Header) http.Header {
+	h2 := h.Clone()
+	for k := range h2 {
+		if d.headers[k] {
+			h2[k] = []string{redacted}
+		}
+	}
+	return h2
+}
+
+func (d *dumper) body(contentType string, body []byte, size int64) string {
+	if size == 0 && len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range d.options.SkipContentTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return fmt.Sprintf("[%s body omitted]", mediaType)
+		}
+	}
+	truncated := d.options.MaxBodySize > 0 && len(body) > d.options.MaxBodySize
+	if truncated {
+		body = body[:d.options.MaxBodySize]
+	}
+	if d.fields != nil && strings.Contains(mediaType, "json") {
+		body = d.fields.ReplaceAll(body, []byte(`${1}"`+redacted+`"`))
+	}
+	if truncated {
+		if size > 0 {
+			return fmt.Sprintf("%s...(truncated, %d bytes)", body, size)
+		}
+		return string(body) + "...(truncated)"
+	}
+	return string(body)
+}
+
+// responseBody reads at most MaxBodySize+1 bytes of the response body,
+// and restores the body so that it can still be fully read afterwards.
+func (d *dumper) responseBody(resp *Resp) []byte {
+	if resp.body != nil {
+		return resp.body
+	}
+	response := resp.Response()
+	if response == nil || response.Body == nil || response.Body == http.NoBody {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get(ContentType))
+	for _, t := range d.options.SkipContentTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return nil
+		}
+	}
+	var reader io.Reader = response.Body
+	if d.options.MaxBodySize > 0 {
+		reader = io.LimitReader(response.Body, int64(d.options.MaxBodySize)+1)
+	}
+	data, _ := io.ReadAll(reader)
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), response.Body), response.Body}
+	return data
+}
+
+func (d *dumper) dump(ctx *Context) string {
+	buf := &bytes.Buffer{}
+	if request := ctx.Resp.Request(); request != nil {
+		r2 := *request
+		r2.Header = d.header(request.Header)
+		r2.Body = nil
+		reqData, _ := httputil.DumpRequest(&r2, false)
+		buf.Write(reqData)
+		buf.WriteString(d.body(request.Header.Get(ContentType), ctx.Req.GetBody(), int64(len(ctx.Req.GetBody()))))
+	} else {
+		fmt.Fprintf(buf, "%s %s\r\n", ctx.Req.GetMethod(), ctx.Req.GetURL())
+	}
+	buf.WriteString("\n\n")
+	if response := ctx.Resp.Response(); response != nil {
+		body := d.responseBody(ctx.Resp)
+		r2 := *response
+		r2.Header = d.header(response.Header)
+		r2.Body = nil
+		respData, _ := httputil.DumpResponse(&r2, false)
+		buf.Write(respData)
+		buf.WriteString(d.body(response.Header.Get(ContentType), body, response.ContentLength))
+	}
+	if err := ctx.Resp.Error(); err != nil {
+		fmt.Fprintf(buf, "\n\nerror: %v", err)
+	}
+	return buf.String()
+}
+
+func (d *dumper) log(ctx *Context) {
+	args := []any{"method", ctx.Req.GetMethod(), "host", ctx.Req.GetHost()}
+	if request := ctx.Resp.Request(); request != nil {
+		args = []any{
+			"method", request.Method,
+			"host", request.URL.Host,
+			"query", request.URL.Query(),
+			"req_headers", d.header(request.Header),
+			"req_body", d.body(request.Header.Get(ContentType), ctx.Req.GetBody(), int64(len(ctx.Req.GetBody()))),
+		}
+	}
+	if response := ctx.Resp.Response(); response != nil {
+		body := d.responseBody(ctx.Resp)
+		args = append(args,
+			"status_code", response.StatusCode,
+			"resp_headers", d.header(response.Header),
+			"resp_body", d.body(response.Header.Get(ContentType), body, response.ContentLength),
+		)
+	}
+	args = append(args, "cost", ctx.Resp.Cost())
+	if err := ctx.Resp.Error(); err != nil {
+		args = append(args, "error", err)
+		d.options.Logger.ErrorContext(ctx.Req.Context(), "dump request", args...)
+		return
+	}
+	if ctx.Resp.StatusCode() >= http.StatusInternalServerError {
+		d.options.Logger.ErrorContext(ctx.Req.Context(), "dump request", args...)
+		return
+	}
+	d.options.Logger.InfoContext(ctx.Req.Context(), "dump request", args...)
+}
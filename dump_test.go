@@ -0,0 +1,149 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDumpRedaction(t *testing.T) {
+	body := `{"user":"bob","password":"s3cr\"et","token":12345,"data":"` + strings.Repeat("x", 100) + `"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentType, ContentTypeJSON)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	d := newDumper(DumpWithRedactFields("password", "Token"), DumpWithMaxBodySize(64))
+	var out string
+	resp := NewClient().Get(ts.URL).
+		WithHeader("Authorization", "Bearer abc").
+		Use(func(ctx *Context) {
+			ctx.Next()
+			out = d.dump(ctx)
+		}).Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+
+	for _, leaked := range []string{"Bearer abc", "session=abc", `s3cr`, "12345"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("dump leaked %q:\n%s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, `"user":"bob"`) || !strings.Contains(out, "truncated") {
+		t.Errorf("unexpected dump:\n%s", out)
+	}
+	if got := resp.String(); got != body {
+		t.Fatalf("response body was not restored, got %q", got)
+	}
+}
+
+func TestDumpFailedRequest(t *testing.T) {
+	var out string
+	resp := NewClient().Get("http://127.0.0.1:0").Use(func(ctx *Context) {
+		ctx.Next()
+		out = newDumper().dump(ctx)
+	}).Do()
+	if resp.Error() == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(out, "error:") {
+		t.Fatalf("unexpected dump:\n%s", out)
+	}
+}
+
+type recordLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *recordLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.record("info", args)
+}
+
+func (l *recordLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.record("error", args)
+}
+
+func (l *recordLogger) record(level string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, level+" "+fmt.Sprint(args...))
+}
+
+func TestLogHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	logger := &recordLogger{}
+	c := NewClient()
+	c.Use(LogHandler(logger))
+	if body := c.Get(ts.URL).WithHeader("Authorization", "Bearer abc").Do().String(); body != "ok" {
+		t.Fatalf("response body was not restored, got %q", body)
+	}
+	if len(logger.records) != 1 || !strings.HasPrefix(logger.records[0], "info") ||
+		strings.Contains(logger.records[0], "Bearer abc") || !strings.Contains(logger.records[0], redacted) {
+		t.Fatalf("unexpected records %q", logger.records)
+	}
+
+	// sampled out successful requests are not logged, unlike 5xx responses and errors
+	logger = &recordLogger{}
+	c = NewClient()
+	c.Use(LogHandlerWithOptions(DumpWithLogger(logger), DumpWithSampleRate(0)))
+	c.Get(ts.URL).Do()
+	c.Get(ts.URL + "/fail").Do()
+	c.Get("http://127.0.0.1:0").Do()
+	if len(logger.records) != 2 || !strings.HasPrefix(logger.records[0], "error") || !strings.Contains(logger.records[0], "502") ||
+		!strings.HasPrefix(logger.records[1], "error") {
+		t.Fatalf("unexpected records %q", logger.records)
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ContentType, ContentTypeJSON)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{"token":"abc","name":"bob"}`))
+	}))
+	defer ts.Close()
+
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = writer
+	c := NewClient()
+	c.Use(DumpHandler(DumpWithRedactHeaders("X-Secret"), DumpWithRedactFields("token"), DumpWithSampleRate(0)))
+	c.Get(ts.URL).WithHeader("X-Secret", "s3cret").Do()
+	resp := c.Get(ts.URL+"/fail").WithHeader("X-Secret", "s3cret").Do()
+	os.Stdout = stdout
+	_ = writer.Close()
+	data, _ := io.ReadAll(reader)
+	out := string(data)
+
+	if strings.Count(out, "GET /") != 1 || !strings.Contains(out, "GET /fail") || !strings.Contains(out, "503") {
+		t.Fatalf("expected the 503 response only to be dumped:\n%s", out)
+	}
+	if strings.Contains(out, "s3cret") || strings.Contains(out, `"abc"`) || !strings.Contains(out, `"name":"bob"`) {
+		t.Errorf("unexpected dump:\n%s", out)
+	}
+	if body := resp.String(); body != `{"token":"abc","name":"bob"}` {
+		t.Fatalf("response body was not restored, got %q", body)
+	}
+}
@@ -0,0 +1,359 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoint is a replica of an upstream
+type Endpoint struct {
+	URL    string `json:"url"`    // base url put in front of the request url, like PrefixPath
+	Weight int    `json:"weight"` // weight for the weighted balancers, 1 if <= 0
+}
+
+// EndpointState is an endpoint of a pool with its load and health
+type EndpointState struct {
+	Endpoint
+	inFlight int64
+	mu       sync.Mutex
+	healthy  bool
+	// passive outlier detection
+	windowStart  time.Time
+	requests     int
+	failures     int
+	consecutive  int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// InFlight returns the requests in flight to the endpoint
+func (e *EndpointState) InFlight() int64 {
+	return atomic.LoadInt64(&e.inFlight)
+}
+
+// Available returns whether the endpoint passes its health checks and is not ejected
+func (e *EndpointState) Available() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.available(time.Now())
+}
+
+// available is Available, the caller must hold e.mu
+func (e *EndpointState) available(now time.Time) bool {
+	return e.healthy && !now.Before(e.ejectedUntil)
+}
+
+func (e *EndpointState) weight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// EndpointStats is a snapshot of an endpoint of a pool
+type EndpointStats struct {
+	Endpoint
+	InFlight int64
+	Healthy  bool
+	Ejected  bool
+}
+
+type PoolOptions struct {
+	Balancer Balancer
+	// active health checks, disabled if HealthCheckPath is empty
+	HealthCheckPath     string
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	HealthCheckClient   *http.Client
+	// passive outlier ejection, from the errors and 5xx of the responses
+	ConsecutiveFailures int           // failures in a row ejecting an endpoint, disabled if <= 0
+	ErrorRate           float64       // failure rate ejecting an endpoint, disabled if <= 0
+	MinRequests         int           // requests in the interval needed to apply ErrorRate
+	OutlierInterval     time.Duration // interval the error rate is computed over
+	EjectionTime        time.Duration // base ejection time, multiplied by the ejections in a row
+	MaxEjectionPercent  int           // ejected endpoints at most, in percent of the pool
+}
+
+type PoolOption func(*PoolOptions)
+
+func WithBalancer(balancer Balancer) PoolOption {
+	return func(options *PoolOptions) {
+		options.Balancer = balancer
+	}
+}
+
+// WithHealthCheck checks every endpoint with a GET of path every interval,
+// an endpoint answering with an error or a status >= 400 is unavailable until it passes a check
+func WithHealthCheck(path string, interval, timeout time.Duration) PoolOption {
+	return func(options *PoolOptions) {
+		options.HealthCheckPath = path
+		options.HealthCheckInterval = interval
+		options.HealthCheckTimeout = timeout
+	}
+}
+
+func WithHealthCheckClient(client *http.Client) PoolOption {
+	return func(options *PoolOptions) {
+		options.HealthCheckClient = client
+	}
+}
+
+// WithOutlierEjection ejects an endpoint after consecutiveFailures failures in a row,
+// or errorRate failures over at least minRequests requests in an interval
+func WithOutlierEjection(consecutiveFailures int, errorRate float64, minRequests int, interval time.Duration) PoolOption {
+	return func(options *PoolOptions) {
+		options.ConsecutiveFailures = consecutiveFailures
+		options.ErrorRate = errorRate
+		options.MinRequests = minRequests
+		options.OutlierInterval = interval
+	}
+}
+
+// WithEjectionTime sets how long an outlier is ejected for the first time, and how many endpoints may be ejected at once
+func WithEjectionTime(ejectionTime time.Duration, maxEjectionPercent int) PoolOption {
+	return func(options *PoolOptions) {
+		options.EjectionTime = ejectionTime
+		options.MaxEjectionPercent = maxEjectionPercent
+	}
+}
+
+// EndpointPool balances requests over the replicas of an upstream
+type EndpointPool struct {
+	options   PoolOptions
+	mu        sync.RWMutex
+	endpoints []*EndpointState
+	done      chan struct{}
+	once      sync.Once
+}
+
+func NewEndpointPool(endpoints []Endpoint, opts ...PoolOption) *EndpointPool {
+	options := PoolOptions{
+		Balancer:            RoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		ConsecutiveFailures: 5,
+		ErrorRate:           0.5,
+		MinRequests:         10,
+		OutlierInterval:     10 * time.Second,
+		EjectionTime:        30 * time.Second,
+		MaxEjectionPercent:  50,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.HealthCheckClient == nil {
+		options.HealthCheckClient = &http.Client{Timeout: options.HealthCheckTimeout}
+	}
+	p := &EndpointPool{
+		options: options,
+		done:    make(chan struct{}),
+	}
+	p.Update(endpoints)
+	if options.HealthCheckPath != "" && options.HealthCheckInterval > 0 {
+		go p.healthCheckLoop()
+	}
+	return p
+}
+
+// Update replaces the endpoints of the pool, the state of the unchanged endpoints is preserved
+func (p *EndpointPool) Update(endpoints []Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	existing := make(map[string]*EndpointState, len(p.endpoints))
+	for _, e := range p.endpoints {
+		existing[e.URL] = e
+	}
+	states := make([]*EndpointState, 0, len(endpoints))
+	for _, e := range endpoints {
+		// states are kept as long as their endpoint is unchanged, balancers rely on it
+		if state, ok := existing[e.URL]; ok && state.Endpoint == e {
+			states = append(states, state)
+			continue
+		}
+		states = append(states, &EndpointState{Endpoint: e, healthy: true})
+	}
+	p.endpoints = states
+}
+
+// Pick returns the endpoint of a request, when no endpoint is available all of them are candidates
+func (p *EndpointPool) Pick(r *Req) (*EndpointState, error) {
+	p.mu.RLock()
+	endpoints := p.endpoints
+	p.mu.RUnlock()
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	now := time.Now()
+	available := make([]*EndpointState, 0, len(endpoints))
+	for _, e := range endpoints {
+		e.mu.Lock()
+		if e.available(now) {
+			available = append(available, e)
+		}
+		e.mu.Unlock()
+	}
+	if len(available) == 0 {
+		available = endpoints
+	}
+	e := p.options.Balancer.Pick(r, available)
+	if e == nil {
+		return nil, ErrNoEndpoint
+	}
+	return e, nil
+}
+
+// Endpoints returns a snapshot of the endpoints of the pool
+func (p *EndpointPool) Endpoints() []EndpointStats {
+	p.mu.RLock()
+	endpoints := p.endpoints
+	p.mu.RUnlock()
+	now := time.Now()
+	stats := make([]EndpointStats, len(endpoints))
+	for i, e := range endpoints {
+		e.mu.Lock()
+		stats[i] = EndpointStats{
+			Endpoint: e.Endpoint,
+			InFlight: e.InFlight(),
+			Healthy:  e.healthy,
+			Ejected:  now.Before(e.ejectedUntil),
+		}
+		e.mu.Unlock()
+	}
+	return stats
+}
+
+// Close stops the health checks
+func (p *EndpointPool) Close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *EndpointPool) acquire(e *EndpointState) {
+	atomic.AddInt64(&e.inFlight, 1)
+}
+
+// release records the result of a request for the outlier ejection
+func (p *EndpointPool) release(e *EndpointState, resp *Resp) {
+	atomic.AddInt64(&e.inFlight, -1)
+	err := resp.Error()
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	failure := err != nil || resp.StatusCode() >= http.StatusInternalServerError
+	now := time.Now()
+	if !e.record(failure, now, &p.options) {
+		return
+	}
+	// the other endpoints are counted without holding e.mu
+	if !p.mayEject(e, now) {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.available(now) {
+		e.ejections++
+		ejections := e.ejections
+		if ejections > 10 {
+			ejections = 10
+		}
+		e.ejectedUntil = now.Add(p.options.EjectionTime * time.Duration(ejections))
+		e.windowStart, e.requests, e.failures, e.consecutive = now, 0, 0, 0
+	}
+}
+
+// record counts a request and returns whether the endpoint became an outlier
+func (e *EndpointState) record(failure bool, now time.Time, options *PoolOptions) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Sub(e.windowStart) >= options.OutlierInterval {
+		e.windowStart, e.requests, e.failures = now, 0, 0
+	}
+	e.requests++
+	if !failure {
+		e.consecutive = 0
+		if !now.Before(e.ejectedUntil) {
+			e.ejections = 0
+		}
+		return false
+	}
+	e.failures++
+	e.consecutive++
+	if !e.available(now) {
+		return false
+	}
+	if options.ConsecutiveFailures > 0 && e.consecutive >= options.ConsecutiveFailures {
+		return true
+	}
+	return options.ErrorRate > 0 && e.requests >= options.MinRequests && float64(e.failures)/float64(e.requests) >= options.ErrorRate
+}
+
+// mayEject checks that ejecting e keeps the ejected endpoints under MaxEjectionPercent
+func (p *EndpointPool) mayEject(e *EndpointState, now time.Time) bool {
+	p.mu.RLock()
+	endpoints := p.endpoints
+	p.mu.RUnlock()
+	ejected := 1
+	for _, other := range endpoints {
+		if other == e {
+			continue
+		}
+		other.mu.Lock()
+		if now.Before(other.ejectedUntil) {
+			ejected++
+		}
+		other.mu.Unlock()
+	}
+	return ejected*100 <= p.options.MaxEjectionPercent*len(endpoints)
+}
+
+func (p *EndpointPool) healthCheckLoop() {
+	p.healthCheck()
+	ticker := time.NewTicker(p.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.healthCheck()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *EndpointPool) healthCheck() {
+	p.mu.RLock()
+	endpoints := p.endpoints
+	p.mu.RUnlock()
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *EndpointState) {
+			defer wg.Done()
+			healthy := p.check(e)
+			e.mu.Lock()
+			e.healthy = healthy
+			e.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (p *EndpointPool) check(e *EndpointState) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.HealthCheckTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, e.URL+p.options.HealthCheckPath, nil)
+	if err != nil {
+		return false
+	}
+	response, err := p.options.HealthCheckClient.Do(request)
+	if err != nil {
+		return false
+	}
+	_ = response.Body.Close()
+	return response.StatusCode < http.StatusBadRequest
+}
@@ -0,0 +1,125 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEndpointPool(t *testing.T) {
+	hits := map[string]int{}
+	newServer := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				w.WriteHeader(status)
+				return
+			}
+			hits[name]++
+			w.WriteHeader(status)
+		}))
+	}
+	good, bad := newServer("good", http.StatusOK), newServer("bad", http.StatusInternalServerError)
+	defer good.Close()
+	defer bad.Close()
+
+	pool := NewEndpointPool([]Endpoint{{URL: good.URL}, {URL: bad.URL}},
+		WithOutlierEjection(2, 0, 0, time.Minute), WithEjectionTime(time.Minute, 50))
+	defer pool.Close()
+	c := NewClient(WithEndpoints(pool))
+	for i := 0; i < 8; i++ {
+		c.Get("/users").Do()
+	}
+	if hits["bad"] != 2 || hits["good"] != 6 {
+		t.Fatalf("expected the bad endpoint to be ejected after 2 failures, got %v", hits)
+	}
+	if stats := pool.Endpoints(); stats[0].Ejected || !stats[1].Ejected {
+		t.Fatalf("unexpected endpoints %+v", stats)
+	}
+	if resp := c.Get(good.URL + "/absolute").Do(); resp.Request().URL.Host != good.Listener.Addr().String() {
+		t.Errorf("absolute urls must not be balanced, got %s", resp.Request().URL)
+	}
+
+	// a reused request does not keep the endpoint picked by its previous send
+	req := c.Get("/users")
+	req.Do()
+	if resp := req.WithURL(good.URL + "/reused").Do(); resp.Error() != nil || resp.Request().URL.String() != good.URL+"/reused" {
+		t.Errorf("unexpected reused request %v, %v", resp.Request().URL, resp.Error())
+	}
+
+	// health checks make the bad endpoint unavailable, even once its ejection is over
+	checked := NewEndpointPool([]Endpoint{{URL: good.URL}, {URL: bad.URL}}, WithHealthCheck("/health", 0, time.Second))
+	checked.healthCheck()
+	if stats := checked.Endpoints(); !stats[0].Healthy || stats[1].Healthy {
+		t.Fatalf("unexpected endpoints %+v", stats)
+	}
+	for i := 0; i < 3; i++ {
+		if e, _ := checked.Pick(New()); e.URL != good.URL {
+			t.Fatalf("picked an unhealthy endpoint %s", e.URL)
+		}
+	}
+}
+
+func TestBalancers(t *testing.T) {
+	endpoints := []*EndpointState{
+		{Endpoint: Endpoint{URL: "a", Weight: 2}},
+		{Endpoint: Endpoint{URL: "b", Weight: 1}},
+	}
+	wrr := WeightedRoundRobin()
+	var picks string
+	for i := 0; i < 6; i++ {
+		picks += wrr.Pick(nil, endpoints).URL
+	}
+	if picks != "abaaba" {
+		t.Errorf("unexpected weighted picks %s", picks)
+	}
+	// the endpoints which are gone are forgotten
+	for i := 0; i < 100; i++ {
+		wrr.Pick(nil, []*EndpointState{{Endpoint: Endpoint{URL: strconv.Itoa(i)}}})
+	}
+	if current := wrr.(*weightedRoundRobin).current; len(current) != 1 {
+		t.Errorf("expected the state of the last endpoint only, got %v", current)
+	}
+	endpoints[0].inFlight = 3
+	endpoints[1].inFlight = 2
+	if e := LeastInFlight().Pick(nil, endpoints); e.URL != "a" {
+		t.Errorf("expected the least loaded endpoint by weight, got %s", e.URL)
+	}
+	if e := PowerOfTwoChoices().Pick(nil, endpoints); e.URL != "a" {
+		t.Errorf("expected the less loaded of two endpoints, got %s", e.URL)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	var endpoints []*EndpointState
+	for _, u := range []string{"a", "b", "c", "d"} {
+		endpoints = append(endpoints, &EndpointState{Endpoint: Endpoint{URL: u}})
+	}
+	balancer := ConsistentHash(func(r *Req) string { return r.GetQueryParams().Get("key") }, 0)
+	pick := func(key string, endpoints []*EndpointState) string {
+		return balancer.Pick(New().WithQueryParam("key", key), endpoints).URL
+	}
+	before := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		before[key] = pick(key, endpoints)
+		counts[before[key]]++
+		if pick(key, endpoints) != before[key] {
+			t.Fatalf("key %s moved without any change", key)
+		}
+	}
+	for u, n := range counts {
+		if n < 150 || n > 350 {
+			t.Errorf("unbalanced ring, %s got %d keys of 1000", u, n)
+		}
+	}
+	// removing an endpoint only remaps its own keys
+	for key, u := range before {
+		after := pick(key, endpoints[:3])
+		if u != "d" && after != u {
+			t.Fatalf("key %s moved from %s to %s", key, u, after)
+		}
+	}
+}
@@ -1,6 +1,14 @@
 package goreq
 
-import "errors"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
 
 // define errors
 var (
@@ -13,4 +21,53 @@ var (
 	ErrNoUnmarshal      = errors.New("resp: no unmarshal")
 	ErrNoMarshal        = errors.New("req: no marshal")
 	ErrParseStruct      = errors.New("req: can not parse struct param")
+	ErrInvalidCurl      = errors.New("req: invalid curl command")
+	ErrHandlerNotFound  = errors.New("client: handler not found")
+	ErrHandlerReserved  = errors.New("client: handler is reserved")
+	ErrNoEndpoint       = errors.New("client: no endpoint available")
+	ErrNoResolver       = errors.New("client: no resolver for service url")
+	ErrNoProxy          = errors.New("client: no proxy")
+)
+
+// error kinds returned by ErrorKind, low cardinality values suitable for metrics labels
+const (
+	ErrorKindTimeout    = "timeout"
+	ErrorKindCanceled   = "canceled"
+	ErrorKindDNS        = "dns"
+	ErrorKindConnection = "connection"
+	ErrorKindTLS        = "tls"
+	ErrorKindOther      = "other"
 )
+
+// ErrorKind classifies err into one of the ErrorKind constants, "" if err is nil
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	var (
+		dnsErr    *net.DNSError
+		netErr    net.Error
+		opErr     *net.OpError
+		certErr   *tls.CertificateVerificationError
+		recordErr tls.RecordHeaderError
+		alertErr  tls.AlertError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		invalid   x509.CertificateInvalidError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authErr), errors.As(err, &hostErr), errors.As(err, &invalid):
+		return ErrorKindTLS
+	case errors.As(err, &opErr), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindConnection
+	}
+	return ErrorKindOther
+}
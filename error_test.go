@@ -0,0 +1,58 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{nil, ""},
+		{context.Canceled, ErrorKindCanceled},
+		{fmt.Errorf("send: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, ErrorKindDNS},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrorKindConnection},
+		{ErrNoURL, ErrorKindOther},
+	}
+	for _, tt := range tests {
+		if kind := ErrorKind(tt.err); kind != tt.kind {
+			t.Errorf("ErrorKind(%v) = %q, expected %q", tt.err, kind, tt.kind)
+		}
+	}
+}
+
+func TestErrorKindOfResponses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer ts.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	c := NewClient(WithTimeout(10 * time.Millisecond))
+	if kind := ErrorKind(c.Get(ts.URL).Do().Error()); kind != ErrorKindTimeout {
+		t.Errorf("expected a timeout, got %q", kind)
+	}
+	if kind := ErrorKind(NewClient().Get(secure.URL).Do().Error()); kind != ErrorKindTLS {
+		t.Errorf("expected a tls error for an unknown authority, got %q", kind)
+	}
+	if kind := ErrorKind(NewClient().Get(closed.URL).Do().Error()); kind != ErrorKindConnection {
+		t.Errorf("expected a connection error, got %q", kind)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if kind := ErrorKind(NewClient().Get(ts.URL).WithContext(ctx).Do().Error()); kind != ErrorKindCanceled {
+		t.Errorf("expected a canceled request, got %q", kind)
+	}
+}
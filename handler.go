@@ -7,8 +7,8 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
+	"time"
 )
 
 type (
@@ -16,6 +16,12 @@ type (
 	HandlerChain []HandlerFunc
 )
 
+// names of the handlers every client starts with
+const (
+	HandlerNameRecovery = "recovery"
+	HandlerNameDo       = "do"
+)
+
 type Logger interface {
 	InfoContext(ctx context.Context, msg string, args ...any)
 	ErrorContext(ctx context.Context, msg string, args ...any)
@@ -31,36 +37,33 @@ func Recovery() HandlerFunc {
 	}
 }
 
-func DumpHandler() HandlerFunc {
+// DumpHandler prints requests and responses, sensitive headers are redacted and bodies are truncated by default
+func DumpHandler(opts ...DumpOption) HandlerFunc {
+	d := newDumper(opts...)
 	return func(ctx *Context) {
 		ctx.Next()
-		ctx.Resp.Request().Body = io.NopCloser(bytes.NewReader(ctx.Req.GetBody()))
-		fmt.Println(ctx.Resp.Dump())
+		if d.sampled(ctx) {
+			fmt.Println(d.dump(ctx))
+		}
 	}
 }
 
+// LogHandler logs requests and responses to the logger, slog.Default() if not specified
 func LogHandler(logger ...Logger) HandlerFunc {
+	if len(logger) == 0 {
+		return LogHandlerWithOptions()
+	}
+	return LogHandlerWithOptions(DumpWithLogger(logger[0]))
+}
+
+// LogHandlerWithOptions logs requests and responses,
+// sensitive headers are redacted and bodies are truncated by default
+func LogHandlerWithOptions(opts ...DumpOption) HandlerFunc {
+	d := newDumper(opts...)
 	return func(ctx *Context) {
 		ctx.Next()
-		ctx.Resp.Request().Body = io.NopCloser(bytes.NewReader(ctx.Req.GetBody()))
-		host := ctx.Resp.Request().Host
-		method := ctx.Resp.Request().Method
-		query := ctx.Resp.Request().URL.Query()
-		reqHeaders := ctx.Resp.Request().Header
-		reqBody := ctx.Req.GetBody()
-		statusCode := ctx.Resp.StatusCode()
-		respHeaders := ctx.Resp.Response().Header
-		respBody, _ := ctx.Resp.AsBytes()
-		var l Logger
-		if len(logger) == 0 {
-			l = slog.Default()
-		} else {
-			l = logger[0]
-		}
-		if statusCode >= http.StatusInternalServerError {
-			l.ErrorContext(ctx.Req.Context(), "dump request", "method", method, "host", host, "query", query, "req_headers", reqHeaders, "req_body", string(reqBody), "status_code", statusCode, "resp_headers", respHeaders, "resp_body", string(respBody))
-		} else {
-			l.InfoContext(ctx.Req.Context(), "dump request", "method", method, "host", host, "query", query, "req_headers", reqHeaders, "req_body", string(reqBody), "status_code", statusCode, "resp_headers", respHeaders, "resp_body", string(respBody))
+		if d.sampled(ctx) {
+			d.log(ctx)
 		}
 	}
 }
@@ -85,3 +88,87 @@ func CompressHandler() HandlerFunc {
 		}
 	}
 }
+
+// RetryHandler sends idempotent requests again after transport errors and 5xx responses,
+// at most retries times and waiting backoff before each retry. The retry hooks run before every retry,
+// with the response of the failed attempt. Handlers after it run again for every attempt.
+// The upload files are read in memory first, so that every attempt sends them whole.
+func RetryHandler(retries int, backoff time.Duration) HandlerFunc {
+	return func(ctx *Context) {
+		if retries <= 0 || !isIdempotent(ctx.Req.GetMethod()) {
+			return
+		}
+		uploads, err := readUploads(ctx.Req)
+		if err != nil {
+			ctx.Resp.SetError(err)
+			ctx.Abort()
+			return
+		}
+		for attempt := 0; ; attempt++ {
+			try := ctx.Copy()
+			for i, data := range uploads {
+				try.Req.uploads[i].File = io.NopCloser(bytes.NewReader(data))
+			}
+			try.Next()
+			*ctx.Resp = *try.Resp
+			if attempt >= retries || !shouldRetry(ctx.Req, ctx.Resp) {
+				break
+			}
+			if response := ctx.Resp.Response(); response != nil && response.Body != nil {
+				_, _ = io.Copy(io.Discard, response.Body)
+				_ = response.Body.Close()
+			}
+			ctx.Retrying(attempt + 1)
+			if backoff > 0 {
+				timer := time.NewTimer(backoff)
+				select {
+				case <-timer.C:
+				case <-ctx.Req.Context().Done():
+					timer.Stop()
+					ctx.Abort()
+					return
+				}
+			}
+		}
+		ctx.Abort()
+	}
+}
+
+// readUploads reads and closes the files of r's uploads, which are then read from memory
+func readUploads(r *Req) ([][]byte, error) {
+	if len(r.uploads) == 0 {
+		return nil, nil
+	}
+	uploads := make([][]byte, len(r.uploads))
+	for i, upload := range r.uploads {
+		data, err := io.ReadAll(upload.File)
+		_ = upload.File.Close()
+		if err != nil {
+			return nil, err
+		}
+		uploads[i] = data
+		r.uploads[i].File = io.NopCloser(bytes.NewReader(data))
+	}
+	return uploads, nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry returns whether resp failed for a reason another attempt may not run into,
+// timeouts and connection errors or 5xx responses
+func shouldRetry(r *Req, resp *Resp) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err := resp.Error(); err != nil {
+		kind := ErrorKind(err)
+		return kind == ErrorKindTimeout || kind == ErrorKindConnection
+	}
+	return resp.StatusCode() >= http.StatusInternalServerError
+}
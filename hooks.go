@@ -0,0 +1,123 @@
+package goreq
+
+import "fmt"
+
+// hooks are lightweight alternatives to a HandlerFunc, they run at fixed points of the request lifecycle:
+//   - before request hooks run inside doHandler, after all handlers and before the request is built
+//   - after response hooks run inside doHandler, once the transport returned a response
+//   - error hooks run after the whole chain, when the response holds an error
+//   - retry hooks run whenever a handler calls Context.Retrying, like RetryHandler before every retry
+//
+// Client hooks run before the hooks of the request.
+type (
+	BeforeRequestHook func(*Req) error
+	AfterResponseHook func(*Resp) error
+	ErrorHook         func(*Req, *Resp, error)
+	RetryHook         func(r *Req, resp *Resp, attempt int)
+)
+
+type hooks struct {
+	beforeRequest []BeforeRequestHook
+	afterResponse []AfterResponseHook
+	onError       []ErrorHook
+	onRetry       []RetryHook
+}
+
+func (h hooks) clone() hooks {
+	return hooks{
+		beforeRequest: append([]BeforeRequestHook(nil), h.beforeRequest...),
+		afterResponse: append([]AfterResponseHook(nil), h.afterResponse...),
+		onError:       append([]ErrorHook(nil), h.onError...),
+		onRetry:       append([]RetryHook(nil), h.onRetry...),
+	}
+}
+
+func (h hooks) runBeforeRequest(r *Req) error {
+	for _, hook := range h.beforeRequest {
+		if err := hook(r); err != nil {
+			return fmt.Errorf("req: before request hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h hooks) runAfterResponse(resp *Resp) error {
+	for _, hook := range h.afterResponse {
+		if err := hook(resp); err != nil {
+			return fmt.Errorf("resp: after response hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h hooks) runError(r *Req, resp *Resp, err error) {
+	for _, hook := range h.onError {
+		hook(r, resp, err)
+	}
+}
+
+func (h hooks) runRetry(r *Req, resp *Resp, attempt int) {
+	for _, hook := range h.onRetry {
+		hook(r, resp, attempt)
+	}
+}
+
+// OnBeforeRequest adds a hook called before every request is built,
+// an error aborts the request.
+func (c *client) OnBeforeRequest(hook BeforeRequestHook) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks.beforeRequest = append(c.hooks.beforeRequest, hook)
+	return c
+}
+
+// OnAfterResponse adds a hook called after every response is received,
+// an error is set to the response.
+func (c *client) OnAfterResponse(hook AfterResponseHook) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks.afterResponse = append(c.hooks.afterResponse, hook)
+	return c
+}
+
+// OnError adds a hook called when a request failed.
+func (c *client) OnError(hook ErrorHook) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks.onError = append(c.hooks.onError, hook)
+	return c
+}
+
+// OnRetry adds a hook called before a request is retried.
+func (c *client) OnRetry(hook RetryHook) Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks.onRetry = append(c.hooks.onRetry, hook)
+	return c
+}
+
+// OnBeforeRequest adds a hook called before this request is built,
+// an error aborts the request.
+func (r *Req) OnBeforeRequest(hook BeforeRequestHook) *Req {
+	r.hooks.beforeRequest = append(r.hooks.beforeRequest, hook)
+	return r
+}
+
+// OnAfterResponse adds a hook called after the response of this request is received,
+// an error is set to the response.
+func (r *Req) OnAfterResponse(hook AfterResponseHook) *Req {
+	r.hooks.afterResponse = append(r.hooks.afterResponse, hook)
+	return r
+}
+
+// OnError adds a hook called when this request failed.
+func (r *Req) OnError(hook ErrorHook) *Req {
+	r.hooks.onError = append(r.hooks.onError, hook)
+	return r
+}
+
+// OnRetry adds a hook called before this request is retried.
+func (r *Req) OnRetry(hook RetryHook) *Req {
+	r.hooks.onRetry = append(r.hooks.onRetry, hook)
+	return r
+}
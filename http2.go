@@ -0,0 +1,91 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// h2cProbeTimeout bounds the probe of a host without deadline in the request context
+const h2cProbeTimeout = 5 * time.Second
+
+// h2cTransport sends the requests of http urls with cleartext HTTP/2 with prior knowledge,
+// and the other requests, like the https ones, with the base transport and its HTTP/1 and HTTP/2.
+// Every host is probed once with the connection preface, the ones answering with HTTP/1 are sent HTTP/1.
+type h2cTransport struct {
+	base  http.RoundTripper
+	h2c   http.RoundTripper
+	dial  DialContext
+	hosts sync.Map // host:port => whether it speaks cleartext HTTP/2
+}
+
+func newH2CTransport(base, h2c http.RoundTripper, dial DialContext) *h2cTransport {
+	return &h2cTransport{base: base, h2c: h2c, dial: dial}
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return t.base.RoundTrip(req)
+	}
+	address := req.URL.Host
+	if req.URL.Port() == "" {
+		address = net.JoinHostPort(req.URL.Hostname(), "80")
+	}
+	if !t.speaksH2C(req.Context(), address) {
+		return t.base.RoundTrip(req)
+	}
+	return t.h2c.RoundTrip(req)
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	for _, rt := range []http.RoundTripper{t.base, t.h2c} {
+		if c, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			c.CloseIdleConnections()
+		}
+	}
+}
+
+// speaksH2C returns whether the server at address speaks cleartext HTTP/2, hosts which could not be probed
+// are sent the prior knowledge and probed again by the next request
+func (t *h2cTransport) speaksH2C(ctx context.Context, address string) bool {
+	if h2c, ok := t.hosts.Load(address); ok {
+		return h2c.(bool)
+	}
+	h2c, err := t.probe(ctx, address)
+	if err != nil {
+		return true
+	}
+	t.hosts.Store(address, h2c)
+	return h2c
+}
+
+// probe sends the client connection preface and an empty SETTINGS frame, a HTTP/2 server answers with
+// its SETTINGS frame while the other servers answer with a HTTP/1 error or close the connection.
+// No request is sent, so that servers without HTTP/2 never see one over it.
+func (t *h2cTransport) probe(ctx context.Context, address string) (bool, error) {
+	conn, err := t.dial(ctx, "tcp", address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(h2cProbeTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+	if _, err = io.WriteString(conn, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n\x00\x00\x00\x04\x00\x00\x00\x00\x00"); err != nil {
+		return false, err
+	}
+	head := make([]byte, 5)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(head) != "HTTP/", nil
+}
@@ -0,0 +1,86 @@
+package goreq
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	tls := httptest.NewUnstartedServer(handler)
+	tls.EnableHTTP2 = true
+	tls.StartTLS()
+	defer tls.Close()
+	h2c := httptest.NewUnstartedServer(handler)
+	h2c.Config.Protocols = new(http.Protocols)
+	h2c.Config.Protocols.SetHTTP1(true)
+	h2c.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2c.Start()
+	defer h2c.Close()
+	http1 := httptest.NewUnstartedServer(handler)
+	http1.Config.Protocols = new(http.Protocols)
+	http1.Config.Protocols.SetHTTP1(true)
+	http1.Start()
+	defer http1.Close()
+	http1TLS := httptest.NewUnstartedServer(handler)
+	http1TLS.StartTLS()
+	defer http1TLS.Close()
+
+	tests := []struct {
+		name   string
+		url    string
+		option Option
+		proto  string
+	}{
+		{"default", tls.URL, EnableInsecureTLS(true), "HTTP/1.1"},
+		{"force", tls.URL, EnableHTTP2(true), "HTTP/2.0"},
+		{"disable", tls.URL, EnableHTTP2(false), "HTTP/1.1"},
+		{"h2c", h2c.URL, EnableH2C(true), "HTTP/2.0"},
+		{"h2c http1", http1.URL, EnableH2C(true), "HTTP/1.1"},
+		{"h2c tls", tls.URL, EnableH2C(true), "HTTP/2.0"},
+		{"h2c tls http1", http1TLS.URL, EnableH2C(true), "HTTP/1.1"},
+	}
+	for _, test := range tests {
+		c := NewClient(EnableInsecureTLS(true), test.option, WithHTTP2Ping(time.Minute, time.Second))
+		resp := c.Get(test.url).Do()
+		if resp.Error() != nil {
+			t.Fatalf("%s: %v", test.name, resp.Error())
+		}
+		if resp.Proto() != test.proto || resp.String() != test.proto {
+			t.Errorf("%s: got %s, want %s", test.name, resp.Proto(), test.proto)
+		}
+	}
+}
+
+func TestH2CFallback(t *testing.T) {
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PRI" {
+			// the probe of the connection preface, served as an HTTP/1 request by some versions
+			methods = append(methods, r.Method)
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer ts.Close()
+
+	c := NewClient(EnableH2C(true))
+	for i := 0; i < 2; i++ {
+		resp := c.Post(ts.URL).WithBody("{}").Do()
+		if resp.Error() != nil {
+			t.Fatal(resp.Error())
+		}
+		if resp.Proto() != "HTTP/1.1" || resp.String() != "{}" {
+			t.Errorf("got %q over %s", resp.String(), resp.Proto())
+		}
+	}
+	// the requests are sent once each, over HTTP/1.1
+	if want := []string{http.MethodPost, http.MethodPost}; !equalStrings(methods, want) {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+}
@@ -23,14 +23,36 @@ type Options struct {
 	DialTimeout           time.Duration
 	DialKeepAlive         time.Duration
 	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int // no limit if <= 0
+	ResponseHeaderTimeout time.Duration
+	IdleReadTimeout       time.Duration         // closes connections without data for this long, disabled if <= 0
+	HostPolicies          map[string]HostPolicy // overrides per host or host:port
 	IdleConnTimeout       time.Duration
 	TLSHandshakeTimeout   time.Duration
 	ExpectContinueTimeout time.Duration
 	Transport             http.RoundTripper
 	TLSClientConfig       *tls.Config
-	Proxy                 func(*http.Request) (*url.URL, error)
+	Proxy                 func(*http.Request) (*url.URL, error) // http.ProxyFromEnvironment if nil
+	ProxyConnectHeader    http.Header                           // headers sent to the proxies in CONNECT requests
+	ProxyPool             *ProxyPool                            // proxies rotated over the requests, in place of Proxy
+	HostOverrides         map[string][]string                   // addresses of hosts or host:port, like curl --resolve
+	DNSServers            []string                              // dns servers used in place of the system ones
+	DNSCacheTTL           time.Duration                         // how long resolved addresses are cached, disabled if <= 0
+	DialContext           DialContext                           // dials the connections in place of a net.Dialer with DialTimeout and DialKeepAlive
+	UnixSocket            string                                // unix socket all the requests are sent over
+	ForceHTTP2            bool                                  // negotiate HTTP/2 over tls, which a custom dialer otherwise prevents
+	DisableHTTP2          bool                                  // only speak HTTP/1.1
+	H2C                   bool                                  // speak cleartext HTTP/2 with prior knowledge to http urls
+	HTTP2ReadIdleTimeout  time.Duration                         // idle time of a HTTP/2 connection before a ping is sent
+	HTTP2PingTimeout      time.Duration                         // time without a ping response before a HTTP/2 connection is closed
+	MaxHeaderListSize     int64                                 // max size of the response headers, for HTTP/1.1 and HTTP/2
 	Codecs                codec.Codecs
-	PrefixPath            string // prefix path for all request
+	PrefixPath            string        // prefix path for all request
+	Endpoints             *EndpointPool // endpoints balancing the relative urls, in place of PrefixPath
+	Resolver              Resolver      // resolver of the service urls, like svc://orders/v1/items
+	ResolveInterval       time.Duration // interval the endpoints of the services are resolved again
+	ResolverPoolOptions   []PoolOption  // options of the endpoint pools of the services
 	Errors                []error
 }
 
@@ -43,9 +65,11 @@ func NewOptions() Options {
 		DialTimeout:           30 * time.Second,
 		DialKeepAlive:         30 * time.Second,
 		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		ResolveInterval:       30 * time.Second,
 		Transport:             nil,
 		TLSClientConfig:       nil,
 		Proxy:                 nil,
@@ -106,6 +130,8 @@ func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
 	}
 }
 
+// WithProxyURL sends the requests through a http, https, socks5 or socks5h proxy,
+// credentials in the url are sent in Proxy-Authorization or in the socks5 handshake
 func WithProxyURL(proxyURL string) Option {
 	return func(options *Options) {
 		u, err := url.Parse(proxyURL)
@@ -117,6 +143,131 @@ func WithProxyURL(proxyURL string) Option {
 	}
 }
 
+// WithProxyConnectHeader sets headers sent to the proxies in CONNECT requests
+func WithProxyConnectHeader(header http.Header) Option {
+	return func(options *Options) {
+		options.ProxyConnectHeader = header
+	}
+}
+
+// WithProxyPool rotates the requests over the proxies of pool
+func WithProxyPool(pool *ProxyPool) Option {
+	return func(options *Options) {
+		options.ProxyPool = pool
+	}
+}
+
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(options *Options) {
+		options.MaxIdleConnsPerHost = n
+	}
+}
+
+func WithMaxConnsPerHost(n int) Option {
+	return func(options *Options) {
+		options.MaxConnsPerHost = n
+	}
+}
+
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.ResponseHeaderTimeout = timeout
+	}
+}
+
+// WithIdleReadTimeout closes the connections on which no data arrived for timeout, idle ones included
+func WithIdleReadTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.IdleReadTimeout = timeout
+	}
+}
+
+// WithHostPolicy overrides the connection pool settings of host, or host:port
+func WithHostPolicy(host string, policy HostPolicy) Option {
+	return func(options *Options) {
+		// the map may be shared with the client this one is cloned from
+		policies := make(map[string]HostPolicy, len(options.HostPolicies)+1)
+		for k, v := range options.HostPolicies {
+			policies[k] = v
+		}
+		policies[host] = policy
+		options.HostPolicies = policies
+	}
+}
+
+// WithHostOverride resolves host, or host:port, to addrs like curl --resolve
+func WithHostOverride(host string, addrs ...string) Option {
+	return func(options *Options) {
+		// the map may be shared with the client this one is cloned from
+		overrides := make(map[string][]string, len(options.HostOverrides)+1)
+		for k, v := range options.HostOverrides {
+			overrides[k] = v
+		}
+		overrides[host] = addrs
+		options.HostOverrides = overrides
+	}
+}
+
+// WithDNSServers resolves the hosts with servers, like 8.8.8.8 or 10.0.0.2:53, in place of the system ones
+func WithDNSServers(servers ...string) Option {
+	return func(options *Options) {
+		options.DNSServers = servers
+	}
+}
+
+// WithDNSCacheTTL caches the resolved addresses for ttl
+func WithDNSCacheTTL(ttl time.Duration) Option {
+	return func(options *Options) {
+		options.DNSCacheTTL = ttl
+	}
+}
+
+// WithDialContext dials the connections of the client's transport with dial
+func WithDialContext(dial DialContext) Option {
+	return func(options *Options) {
+		options.DialContext = dial
+	}
+}
+
+// WithUnixSocket sends all the requests over the unix socket at path, without proxy.
+// Single requests can use http+unix urls instead.
+func WithUnixSocket(path string) Option {
+	return func(options *Options) {
+		options.UnixSocket = path
+	}
+}
+
+// EnableHTTP2 forces or disables HTTP/2 over tls
+func EnableHTTP2(enable bool) Option {
+	return func(options *Options) {
+		options.ForceHTTP2 = enable
+		options.DisableHTTP2 = !enable
+	}
+}
+
+// EnableH2C sends the requests of http urls with cleartext HTTP/2 with prior knowledge, without HTTP/1.1 upgrade.
+// Every host is first probed once with the connection preface, the ones answering with HTTP/1 are sent HTTP/1.1,
+// https urls negotiate the protocol as usual.
+func EnableH2C(enable bool) Option {
+	return func(options *Options) {
+		options.H2C = enable
+	}
+}
+
+// WithHTTP2Ping pings HTTP/2 connections idle for readIdleTimeout, and closes them if no response arrives within pingTimeout
+func WithHTTP2Ping(readIdleTimeout, pingTimeout time.Duration) Option {
+	return func(options *Options) {
+		options.HTTP2ReadIdleTimeout = readIdleTimeout
+		options.HTTP2PingTimeout = pingTimeout
+	}
+}
+
+func WithMaxHeaderListSize(size int64) Option {
+	return func(options *Options) {
+		options.MaxHeaderListSize = size
+	}
+}
+
 func WithCodec(codec codec.Codec) Option {
 	return func(options *Options) {
 		options.Codecs.Set(codec.Name(), codec)
@@ -128,3 +279,24 @@ func WithPrefixPath(prefixPath string) Option {
 		options.PrefixPath = prefixPath
 	}
 }
+
+// WithEndpoints balances the requests with a relative url over the endpoints of pool
+func WithEndpoints(pool *EndpointPool) Option {
+	return func(options *Options) {
+		options.Endpoints = pool
+	}
+}
+
+// WithResolver resolves the service urls, like svc://orders/v1/items, into an endpoint pool per service
+func WithResolver(resolver Resolver, opts ...PoolOption) Option {
+	return func(options *Options) {
+		options.Resolver = resolver
+		options.ResolverPoolOptions = opts
+	}
+}
+
+func WithResolveInterval(interval time.Duration) Option {
+	return func(options *Options) {
+		options.ResolveInterval = interval
+	}
+}
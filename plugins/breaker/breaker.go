@@ -0,0 +1,273 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker keeps a circuit per key returned by Options.KeyFunc
+type CircuitBreaker struct {
+	options  Options
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func New(opts ...Option) *CircuitBreaker {
+	return &CircuitBreaker{
+		options:  newOptions(opts...),
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// Breaker returns a handler rejecting requests with a CircuitError while their circuit is open
+func Breaker(opts ...Option) goreq.HandlerFunc {
+	return New(opts...).Handler()
+}
+
+func (b *CircuitBreaker) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		key := b.options.KeyFunc(ctx.Req)
+		c := b.circuit(key)
+		generation, err := c.before(time.Now())
+		if err != nil {
+			ctx.Resp.SetError(err)
+			ctx.Abort()
+			return
+		}
+		finished := false
+		// deferred so that a panic below does not leak a half open probe
+		defer func() {
+			// panics and requests canceled by the caller say nothing about the upstream
+			if !finished || errors.Is(ctx.Resp.Error(), context.Canceled) {
+				c.ignore(generation)
+				return
+			}
+			c.after(generation, b.options.IsFailure(ctx.Resp), time.Now())
+		}()
+		ctx.Next()
+		finished = true
+	}
+}
+
+// State returns the state of the circuit of key
+func (b *CircuitBreaker) State(key string) State {
+	return b.circuit(key).currentState(time.Now())
+}
+
+func (b *CircuitBreaker) circuit(key string) *circuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{
+			key:     key,
+			options: &b.options,
+			window:  newWindow(b.options.Window, b.options.Buckets),
+		}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+type circuit struct {
+	key         string
+	options     *Options
+	mu          sync.Mutex
+	state       State
+	generation  uint64
+	openedAt    time.Time
+	consecutive int
+	window      *window
+	probes      int // probes in flight when half open
+	successes   int // successful probes when half open
+}
+
+// before checks whether a request may pass, and returns the generation it belongs to
+func (c *circuit) before(now time.Time) (uint64, error) {
+	c.mu.Lock()
+	from := c.state
+	c.refresh(now)
+	to := c.state
+	switch c.state {
+	case StateOpen:
+		c.mu.Unlock()
+		c.notify(from, to)
+		return 0, CircuitError{Message: "circuit " + c.key + " is open", Key: c.key, State: StateOpen}
+	case StateHalfOpen:
+		if c.probes >= c.options.HalfOpenRequests {
+			c.mu.Unlock()
+			c.notify(from, to)
+			return 0, CircuitError{Message: "circuit " + c.key + " is half open", Key: c.key, State: StateHalfOpen}
+		}
+		c.probes++
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	c.notify(from, to)
+	return generation, nil
+}
+
+// after records the result of a request, results of an older generation are ignored
+func (c *circuit) after(generation uint64, failure bool, now time.Time) {
+	c.mu.Lock()
+	from := c.state
+	if generation != c.generation {
+		c.mu.Unlock()
+		return
+	}
+	switch c.state {
+	case StateClosed:
+		c.window.add(now, failure)
+		if failure {
+			c.consecutive++
+		} else {
+			c.consecutive = 0
+		}
+		if c.shouldTrip(now) {
+			c.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		c.probes--
+		if failure {
+			c.setState(StateOpen, now)
+			break
+		}
+		c.successes++
+		if c.successes >= c.options.HalfOpenRequests {
+			c.setState(StateClosed, now)
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+}
+
+// ignore releases the probe of a request without recording its result
+func (c *circuit) ignore(generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation && c.state == StateHalfOpen {
+		c.probes--
+	}
+}
+
+func (c *circuit) currentState(now time.Time) State {
+	c.mu.Lock()
+	from := c.state
+	c.refresh(now)
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+	return to
+}
+
+func (c *circuit) shouldTrip(now time.Time) bool {
+	if c.options.ConsecutiveFailures > 0 && c.consecutive >= c.options.ConsecutiveFailures {
+		return true
+	}
+	if c.options.ErrorRate <= 0 {
+		return false
+	}
+	total, failures := c.window.counts(now)
+	return total > 0 && total >= c.options.MinRequests && float64(failures)/float64(total) >= c.options.ErrorRate
+}
+
+// refresh moves an open circuit to half open once the open timeout elapsed, the caller must hold c.mu
+func (c *circuit) refresh(now time.Time) {
+	if c.state == StateOpen && now.Sub(c.openedAt) >= c.options.OpenTimeout {
+		c.setState(StateHalfOpen, now)
+	}
+}
+
+// setState switches to a new generation, the caller must hold c.mu
+func (c *circuit) setState(state State, now time.Time) {
+	c.state = state
+	c.generation++
+	c.consecutive = 0
+	c.probes = 0
+	c.successes = 0
+	switch state {
+	case StateOpen:
+		c.openedAt = now
+	case StateClosed:
+		c.window.reset()
+	}
+}
+
+func (c *circuit) notify(from, to State) {
+	if from != to && c.options.OnStateChange != nil {
+		c.options.OnStateChange(c.key, from, to)
+	}
+}
+
+// window counts requests and failures over a sliding window split in buckets
+type window struct {
+	size    time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	epoch    int64
+	total    int
+	failures int
+}
+
+func newWindow(size time.Duration, n int) *window {
+	if size <= 0 {
+		size = time.Second
+	}
+	return &window{size: size / time.Duration(n), buckets: make([]bucket, n)}
+}
+
+func (w *window) add(now time.Time, failure bool) {
+	epoch := now.UnixNano() / int64(w.size)
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.total++
+	if failure {
+		b.failures++
+	}
+}
+
+func (w *window) counts(now time.Time) (total, failures int) {
+	epoch := now.UnixNano() / int64(w.size)
+	for _, b := range w.buckets {
+		if epoch-b.epoch < int64(len(w.buckets)) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -0,0 +1,138 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	var transitions []string
+	b := New(
+		ConsecutiveFailures(3),
+		OpenTimeout(50*time.Millisecond),
+		OnStateChange(func(key string, from, to State) {
+			transitions = append(transitions, from.String()+">"+to.String())
+		}),
+	)
+	c := goreq.NewClient()
+	c.Use(b.Handler())
+	do := func() *goreq.Resp {
+		return c.Get(ts.URL).WithName("upstream").Do()
+	}
+
+	for i := 0; i < 3; i++ {
+		do()
+	}
+	if b.State("upstream") != StateOpen {
+		t.Fatalf("state = %s, want open", b.State("upstream"))
+	}
+	resp := do()
+	var circuitErr CircuitError
+	if !errors.As(resp.Error(), &circuitErr) || circuitErr.Key != "upstream" {
+		t.Fatalf("error = %v, want CircuitError", resp.Error())
+	}
+	if hits.Load() != 3 {
+		t.Fatalf("open circuit let the request through, hits = %d", hits.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	if resp = do(); resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	if b.State("upstream") != StateClosed {
+		t.Fatalf("state = %s, want closed", b.State("upstream"))
+	}
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	b := New(MinRequests(4), ErrorRate(0.5))
+	c := b.circuit("k")
+	now := time.Now()
+	for _, failure := range []bool{false, true, false} {
+		g, err := c.before(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.after(g, failure, now)
+	}
+	if c.currentState(now) != StateClosed {
+		t.Fatal("tripped below min requests")
+	}
+	g, _ := c.before(now)
+	c.after(g, true, now)
+	if c.currentState(now) != StateOpen {
+		t.Fatal("expected open at 50% error rate")
+	}
+}
+
+func TestBreakerIgnored(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	b := New(ConsecutiveFailures(2), OpenTimeout(time.Millisecond))
+	c := goreq.NewClient()
+	c.Use(b.Handler())
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a canceled request does not reset the consecutive failures
+	c.Get(ts.URL).WithName("upstream").Do()
+	c.Get(ts.URL).WithName("upstream").WithContext(canceled).Do()
+	c.Get(ts.URL).WithName("upstream").Do()
+	if b.State("upstream") != StateOpen {
+		t.Fatalf("state = %s, want open", b.State("upstream"))
+	}
+
+	// neither a canceled probe nor a panicking one closes the circuit or keeps the probe
+	time.Sleep(5 * time.Millisecond)
+	c.Get(ts.URL).WithName("upstream").WithContext(canceled).Do()
+	if b.State("upstream") != StateHalfOpen {
+		t.Fatalf("state = %s, want half-open", b.State("upstream"))
+	}
+	c.OnAfterResponse(func(*goreq.Resp) error {
+		panic("after response")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the hook to panic")
+			}
+		}()
+		c.Get(ts.URL).WithName("upstream").Do()
+	}()
+	if b.State("upstream") != StateHalfOpen {
+		t.Fatalf("state = %s, want half-open", b.State("upstream"))
+	}
+	if _, err := b.circuit("upstream").before(time.Now()); err != nil {
+		t.Fatalf("expected the probe to be released, got %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package breaker
 
 type CircuitError struct {
 	Message string
+	Key     string
+	State   State
 }
 
 func (e CircuitError) Error() string {
@@ -0,0 +1,125 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+type Options struct {
+	KeyFunc             func(*goreq.Req) string
+	Window              time.Duration // length of the sliding window the error rate is computed over
+	Buckets             int           // number of buckets of the sliding window
+	MinRequests         int           // minimum requests in the window before the error rate can trip the circuit
+	ErrorRate           float64       // error rate tripping the circuit, disabled if <= 0
+	ConsecutiveFailures int           // consecutive failures tripping the circuit, disabled if <= 0
+	OpenTimeout         time.Duration // how long the circuit stays open before letting probes through
+	HalfOpenRequests    int           // probes allowed when half open, all of them must succeed to close the circuit
+	IsFailure           func(*goreq.Resp) bool
+	OnStateChange       func(key string, from, to State)
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc:             DefaultKeyFunc,
+		Window:              10 * time.Second,
+		Buckets:             10,
+		MinRequests:         20,
+		ErrorRate:           0.5,
+		ConsecutiveFailures: 0,
+		OpenTimeout:         5 * time.Second,
+		HalfOpenRequests:    1,
+		IsFailure:           DefaultIsFailure,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Buckets <= 0 {
+		options.Buckets = 1
+	}
+	if options.HalfOpenRequests <= 0 {
+		options.HalfOpenRequests = 1
+	}
+	return options
+}
+
+// DefaultKeyFunc uses the request name, or the host if the request has no name
+func DefaultKeyFunc(r *goreq.Req) string {
+	if r.GetName() != "" {
+		return r.GetName()
+	}
+	return r.GetHost()
+}
+
+// DefaultIsFailure counts transport errors and 5xx responses as failures,
+// requests canceled by the caller are neither failures nor successes and are not recorded by the breaker
+func DefaultIsFailure(resp *goreq.Resp) bool {
+	if err := resp.Error(); err != nil {
+		var circuitErr CircuitError
+		return !errors.Is(err, context.Canceled) && !errors.As(err, &circuitErr)
+	}
+	return resp.StatusCode() >= http.StatusInternalServerError
+}
+
+func KeyFunc(keyFunc func(request *goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+// Window sets the sliding window the error rate is computed over, split in buckets
+func Window(window time.Duration, buckets int) Option {
+	return func(options *Options) {
+		options.Window = window
+		options.Buckets = buckets
+	}
+}
+
+func MinRequests(n int) Option {
+	return func(options *Options) {
+		options.MinRequests = n
+	}
+}
+
+func ErrorRate(rate float64) Option {
+	return func(options *Options) {
+		options.ErrorRate = rate
+	}
+}
+
+func ConsecutiveFailures(n int) Option {
+	return func(options *Options) {
+		options.ConsecutiveFailures = n
+	}
+}
+
+func OpenTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.OpenTimeout = timeout
+	}
+}
+
+func HalfOpenRequests(n int) Option {
+	return func(options *Options) {
+		options.HalfOpenRequests = n
+	}
+}
+
+// IsFailure sets the classifier of failed requests
+func IsFailure(isFailure func(*goreq.Resp) bool) Option {
+	return func(options *Options) {
+		options.IsFailure = isFailure
+	}
+}
+
+// OnStateChange sets a callback called when a circuit changes state
+func OnStateChange(onStateChange func(key string, from, to State)) Option {
+	return func(options *Options) {
+		options.OnStateChange = onStateChange
+	}
+}
@@ -0,0 +1,147 @@
+package bulkhead
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// RejectedError is returned when a request finds the bulkhead of its key saturated
+type RejectedError struct {
+	Key     string
+	Timeout bool // the request waited QueueTimeout in the queue, else the queue was full
+}
+
+func (e RejectedError) Error() string {
+	if e.Timeout {
+		return "bulkhead: " + e.Key + " queue timeout"
+	}
+	return "bulkhead: " + e.Key + " is full"
+}
+
+// Stats of the bulkhead of a key
+type Stats struct {
+	InFlight int
+	Queued   int
+}
+
+// Bulkhead bounds the requests in flight per key returned by Options.KeyFunc
+type Bulkhead struct {
+	options Options
+	mu      sync.Mutex
+	keys    map[string]*compartment
+}
+
+func New(opts ...Option) *Bulkhead {
+	return &Bulkhead{
+		options: newOptions(opts...),
+		keys:    make(map[string]*compartment),
+	}
+}
+
+// Limiter returns a handler rejecting requests with a RejectedError when their bulkhead is saturated
+func Limiter(opts ...Option) goreq.HandlerFunc {
+	return New(opts...).Handler()
+}
+
+func (b *Bulkhead) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		key := b.options.KeyFunc(ctx.Req)
+		c := b.compartment(key)
+		if err := c.acquire(ctx.Req, b.options.QueueTimeout); err != nil {
+			ctx.Resp.SetError(err)
+			ctx.Abort()
+			return
+		}
+		defer c.release()
+		ctx.Next()
+	}
+}
+
+// Stats returns the requests in flight and queued per key
+func (b *Bulkhead) Stats() map[string]Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]Stats, len(b.keys))
+	for key, c := range b.keys {
+		stats[key] = c.stats()
+	}
+	return stats
+}
+
+func (b *Bulkhead) compartment(key string) *compartment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.keys[key]
+	if !ok {
+		limit, ok := b.options.Limits[key]
+		if !ok {
+			limit = b.options.Limit
+		}
+		if limit.MaxConcurrent < 1 {
+			limit.MaxConcurrent = 1
+		}
+		c = &compartment{
+			key:   key,
+			limit: limit,
+			slots: make(chan struct{}, limit.MaxConcurrent),
+		}
+		b.keys[key] = c
+	}
+	return c
+}
+
+type compartment struct {
+	key    string
+	limit  Limit
+	slots  chan struct{}
+	mu     sync.Mutex
+	queued int
+}
+
+func (c *compartment) acquire(r *goreq.Req, timeout time.Duration) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	c.mu.Lock()
+	if c.queued >= c.limit.MaxQueue {
+		c.mu.Unlock()
+		return RejectedError{Key: c.key}
+	}
+	c.queued++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.queued--
+		c.mu.Unlock()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	reqCtx := r.Context()
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-expired:
+		return RejectedError{Key: c.key, Timeout: true}
+	case <-reqCtx.Done():
+		return reqCtx.Err()
+	}
+}
+
+func (c *compartment) release() {
+	<-c.slots
+}
+
+func (c *compartment) stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{InFlight: len(c.slots), Queued: c.queued}
+}
@@ -0,0 +1,56 @@
+package bulkhead
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestBulkhead(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	bulkhead := New(MaxConcurrent(1, 1), QueueTimeout(50*time.Millisecond))
+	c := goreq.NewClient()
+	c.Use(bulkhead.Handler())
+	key := c.Get(ts.URL).GetHost()
+
+	first := make(chan *goreq.Resp)
+	go func() { first <- c.Get(ts.URL).Do() }()
+	waitStats(t, bulkhead, key, Stats{InFlight: 1})
+	queued := make(chan *goreq.Resp)
+	go func() { queued <- c.Get(ts.URL).Do() }()
+	waitStats(t, bulkhead, key, Stats{InFlight: 1, Queued: 1})
+
+	var rejected RejectedError
+	if err := c.Get(ts.URL).Do().Error(); !errors.As(err, &rejected) || rejected.Timeout {
+		t.Fatalf("expected the queue to be full, got %v", err)
+	}
+	if err := (<-queued).Error(); !errors.As(err, &rejected) || !rejected.Timeout {
+		t.Fatalf("expected a queue timeout, got %v", err)
+	}
+	release <- struct{}{}
+	if err := (<-first).Error(); err != nil {
+		t.Fatal(err)
+	}
+	waitStats(t, bulkhead, key, Stats{})
+}
+
+func waitStats(t *testing.T, b *Bulkhead, key string, want Stats) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for b.Stats()[key] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got stats %+v, want %+v", b.Stats()[key], want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
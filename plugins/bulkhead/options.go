@@ -0,0 +1,71 @@
+package bulkhead
+
+import (
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// Limit of a bulkhead
+type Limit struct {
+	MaxConcurrent int // requests in flight
+	MaxQueue      int // requests waiting for a slot, requests are rejected at once if <= 0
+}
+
+type Options struct {
+	KeyFunc      func(*goreq.Req) string
+	Limit        Limit            // limit of the keys not in Limits
+	Limits       map[string]Limit // limits per key
+	QueueTimeout time.Duration    // longest wait in the queue, no limit but the request context if <= 0
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc:      ByHost,
+		Limit:        Limit{MaxConcurrent: 10, MaxQueue: 10},
+		Limits:       make(map[string]Limit),
+		QueueTimeout: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// ByHost isolates requests per host
+func ByHost(r *goreq.Req) string {
+	return r.GetHost()
+}
+
+// ByName isolates requests per request name
+func ByName(r *goreq.Req) string {
+	return r.GetName()
+}
+
+func KeyFunc(keyFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+// MaxConcurrent sets the default limit, maxConcurrent requests in flight and maxQueue waiting
+func MaxConcurrent(maxConcurrent, maxQueue int) Option {
+	return func(options *Options) {
+		options.Limit = Limit{MaxConcurrent: maxConcurrent, MaxQueue: maxQueue}
+	}
+}
+
+// KeyMaxConcurrent sets the limit of key
+func KeyMaxConcurrent(key string, maxConcurrent, maxQueue int) Option {
+	return func(options *Options) {
+		options.Limits[key] = Limit{MaxConcurrent: maxConcurrent, MaxQueue: maxQueue}
+	}
+}
+
+func QueueTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.QueueTimeout = timeout
+	}
+}
@@ -0,0 +1,144 @@
+package concurrency
+
+import (
+	"math"
+	"time"
+)
+
+// Algorithm adjusts a concurrency limit from samples of completed requests,
+// it is only called by one goroutine at a time
+type Algorithm interface {
+	Limit() int
+	// Sample records a request which started with inFlight requests in flight, including itself
+	Sample(rtt time.Duration, inFlight int, dropped bool)
+}
+
+type AIMDOptions struct {
+	InitialLimit int           // default 20
+	MinLimit     int           // default 1
+	MaxLimit     int           // default 200
+	BackoffRatio float64       // ratio applied to the limit on drops, default 0.9
+	Timeout      time.Duration // latency counted as a drop, disabled if <= 0
+}
+
+type aimd struct {
+	options AIMDOptions
+	limit   int
+}
+
+// NewAIMD returns an algorithm increasing the limit by one after each success while in use,
+// and multiplying it by BackoffRatio after each drop
+func NewAIMD(o AIMDOptions) Algorithm {
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 200
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 20
+	}
+	if o.BackoffRatio <= 0 || o.BackoffRatio >= 1 {
+		o.BackoffRatio = 0.9
+	}
+	return &aimd{options: o, limit: clamp(o.InitialLimit, o.MinLimit, o.MaxLimit)}
+}
+
+func (a *aimd) Limit() int {
+	return a.limit
+}
+
+func (a *aimd) Sample(rtt time.Duration, inFlight int, dropped bool) {
+	switch {
+	case dropped || (a.options.Timeout > 0 && rtt > a.options.Timeout):
+		a.limit = int(float64(a.limit) * a.options.BackoffRatio)
+	case inFlight*2 >= a.limit:
+		// the limit only grows while it is actually used
+		a.limit++
+	}
+	a.limit = clamp(a.limit, a.options.MinLimit, a.options.MaxLimit)
+}
+
+type GradientOptions struct {
+	InitialLimit int     // default 20
+	MinLimit     int     // default 1
+	MaxLimit     int     // default 200
+	Smoothing    float64 // weight of a new limit, default 0.2
+	Window       int     // samples averaged into the long term latency, default 600
+	// QueueSize returns the requests allowed to queue at the upstream for a limit, default its square root
+	QueueSize func(limit int) int
+}
+
+type gradient struct {
+	options GradientOptions
+	limit   float64
+	longRTT float64 // exponential moving average, in nanoseconds
+	samples int
+}
+
+// NewGradient returns an algorithm scaling the limit by the ratio of the long term latency to the latest one,
+// so that the limit shrinks as soon as the upstream starts queueing
+func NewGradient(o GradientOptions) Algorithm {
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 200
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 20
+	}
+	if o.Smoothing <= 0 || o.Smoothing > 1 {
+		o.Smoothing = 0.2
+	}
+	if o.Window <= 0 {
+		o.Window = 600
+	}
+	if o.QueueSize == nil {
+		o.QueueSize = func(limit int) int { return int(math.Max(1, math.Sqrt(float64(limit)))) }
+	}
+	return &gradient{options: o, limit: float64(clamp(o.InitialLimit, o.MinLimit, o.MaxLimit))}
+}
+
+func (g *gradient) Limit() int {
+	return int(g.limit)
+}
+
+func (g *gradient) Sample(rtt time.Duration, inFlight int, dropped bool) {
+	shortRTT := float64(rtt)
+	if shortRTT <= 0 {
+		return
+	}
+	// warm up with a plain average, then move slowly
+	g.samples++
+	window := g.options.Window
+	if g.samples < window {
+		window = g.samples
+	}
+	g.longRTT += (shortRTT - g.longRTT) / float64(window)
+	if g.longRTT/shortRTT > 2 {
+		// the latency dropped for good, recover faster than the window would
+		g.longRTT *= 0.95
+	}
+	if !dropped && float64(inFlight) < g.limit/2 {
+		// the limit is not used, its latency says nothing about it
+		return
+	}
+	ratio := math.Max(0.5, math.Min(1, g.longRTT/shortRTT))
+	if dropped {
+		ratio = 0.5
+	}
+	limit := g.limit*ratio + float64(g.options.QueueSize(int(g.limit)))
+	limit = g.limit*(1-g.options.Smoothing) + limit*g.options.Smoothing
+	g.limit = math.Max(float64(g.options.MinLimit), math.Min(float64(g.options.MaxLimit), limit))
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// LimitError is returned when a request is shed because its key reached its concurrency limit
+type LimitError struct {
+	Key   string
+	Limit int
+}
+
+func (e LimitError) Error() string {
+	return "concurrency: " + e.Key + " reached its limit of " + strconv.Itoa(e.Limit)
+}
+
+// Stats of the limiter of a key
+type Stats struct {
+	Limit    int
+	InFlight int
+}
+
+// Limiter adapts a concurrency limit per key returned by Options.KeyFunc,
+// from the latency measured by Resp.Cost and the drops reported by Options.IsDrop
+type Limiter struct {
+	options Options
+	mu      sync.Mutex
+	keys    map[string]*limiter
+}
+
+func New(opts ...Option) *Limiter {
+	return &Limiter{
+		options: newOptions(opts...),
+		keys:    make(map[string]*limiter),
+	}
+}
+
+// Limit returns a handler shedding requests with a LimitError above the adaptive limit of their key
+func Limit(opts ...Option) goreq.HandlerFunc {
+	return New(opts...).Handler()
+}
+
+func (l *Limiter) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		key := l.options.KeyFunc(ctx.Req)
+		k := l.limiter(key)
+		inFlight, limit, ok := k.acquire()
+		if !ok {
+			ctx.Resp.SetError(LimitError{Key: key, Limit: limit})
+			ctx.Abort()
+			return
+		}
+		// deferred so that a panic below does not leak the slot
+		defer l.done(ctx, k, inFlight)
+		ctx.Next()
+	}
+}
+
+// done releases the slot of a request, and samples it if it reached the upstream
+func (l *Limiter) done(ctx *goreq.Context, k *limiter, inFlight int) {
+	// requests which never reached the upstream, or were canceled by the caller, say nothing about it
+	if ctx.Resp.Cost() <= 0 || errors.Is(ctx.Resp.Error(), context.Canceled) {
+		k.release()
+		return
+	}
+	k.sample(ctx.Resp.Cost(), inFlight, l.options.IsDrop(ctx.Resp))
+}
+
+// Stats returns the limit and the requests in flight per key
+func (l *Limiter) Stats() map[string]Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]Stats, len(l.keys))
+	for key, k := range l.keys {
+		stats[key] = k.stats()
+	}
+	return stats
+}
+
+func (l *Limiter) limiter(key string) *limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k, ok := l.keys[key]
+	if !ok {
+		k = &limiter{algorithm: l.options.NewAlgorithm()}
+		l.keys[key] = k
+	}
+	return k
+}
+
+type limiter struct {
+	mu        sync.Mutex
+	algorithm Algorithm
+	inFlight  int
+}
+
+func (k *limiter) acquire() (inFlight, limit int, ok bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	limit = k.algorithm.Limit()
+	if k.inFlight >= limit {
+		return k.inFlight, limit, false
+	}
+	k.inFlight++
+	return k.inFlight, limit, true
+}
+
+func (k *limiter) release() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.inFlight--
+}
+
+// sample releases a request and feeds its result to the algorithm
+func (k *limiter) sample(rtt time.Duration, inFlight int, dropped bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.inFlight--
+	k.algorithm.Sample(rtt, inFlight, dropped)
+}
+
+func (k *limiter) stats() Stats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return Stats{Limit: k.algorithm.Limit(), InFlight: k.inFlight}
+}
@@ -0,0 +1,98 @@
+package concurrency
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(AIMDOptions{InitialLimit: 10, MaxLimit: 11, Timeout: time.Second})
+	a.Sample(time.Millisecond, 2, false)
+	if a.Limit() != 10 {
+		t.Fatalf("an unused limit must not grow, got %d", a.Limit())
+	}
+	a.Sample(time.Millisecond, 5, false)
+	a.Sample(time.Millisecond, 5, false)
+	if a.Limit() != 11 {
+		t.Fatalf("expected the limit to grow up to its max, got %d", a.Limit())
+	}
+	a.Sample(2*time.Second, 5, false)
+	if a.Limit() != 9 {
+		t.Fatalf("expected a slow request to back off, got %d", a.Limit())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := NewGradient(GradientOptions{InitialLimit: 20, Window: 10})
+	for i := 0; i < 10; i++ {
+		g.Sample(10*time.Millisecond, 20, false)
+	}
+	grown := g.Limit()
+	if grown <= 20 {
+		t.Fatalf("expected a steady latency to grow the limit, got %d", grown)
+	}
+	for i := 0; i < 10; i++ {
+		g.Sample(100*time.Millisecond, grown, false)
+	}
+	if g.Limit() >= grown {
+		t.Fatalf("expected a rising latency to shrink the limit below %d, got %d", grown, g.Limit())
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	limiter := New(AIMD(AIMDOptions{InitialLimit: 2, MinLimit: 1, BackoffRatio: 0.5}))
+	c := goreq.NewClient()
+	c.Use(limiter.Handler())
+	key := c.Get(ts.URL).GetHost()
+	for i := 0; i < 2; i++ {
+		if err := c.Get(ts.URL).Do().Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := limiter.Stats()[key]; stats != (Stats{Limit: 1}) {
+		t.Fatalf("expected the limit to back off on 503, got %+v", stats)
+	}
+
+	k := limiter.limiter(key)
+	if _, _, ok := k.acquire(); !ok {
+		t.Fatal("expected a slot")
+	}
+	var limited LimitError
+	if err := c.Get(ts.URL).Do().Error(); !errors.As(err, &limited) || limited.Limit != 1 {
+		t.Fatalf("expected the request to be shed, got %v", err)
+	}
+	k.release()
+}
+
+func TestLimiterPanic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	limiter := New(AIMD(AIMDOptions{InitialLimit: 1}))
+	c := goreq.NewClient()
+	c.Use(limiter.Handler())
+	c.OnAfterResponse(func(*goreq.Resp) error {
+		panic("after response")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the hook to panic")
+			}
+		}()
+		c.Get(ts.URL).Do()
+	}()
+	if stats := limiter.Stats()[c.Get(ts.URL).GetHost()]; stats.InFlight != 0 {
+		t.Fatalf("expected the slot to be released by the panic, got %+v", stats)
+	}
+}
@@ -0,0 +1,81 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aiscrm/goreq"
+)
+
+type Options struct {
+	KeyFunc      func(*goreq.Req) string
+	NewAlgorithm func() Algorithm       // creates the algorithm of each key
+	IsDrop       func(*goreq.Resp) bool // whether a response is a sign of overload
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc:      ByHost,
+		NewAlgorithm: func() Algorithm { return NewGradient(GradientOptions{}) },
+		IsDrop:       DefaultIsDrop,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// ByHost limits requests per host
+func ByHost(r *goreq.Req) string {
+	return r.GetHost()
+}
+
+// ByName limits requests per request name
+func ByName(r *goreq.Req) string {
+	return r.GetName()
+}
+
+// DefaultIsDrop treats transport errors, 429 and 503 as drops
+func DefaultIsDrop(resp *goreq.Resp) bool {
+	if err := resp.Error(); err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	code := resp.StatusCode()
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+func KeyFunc(keyFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+// AIMD limits the concurrency with the additive increase, multiplicative decrease algorithm
+func AIMD(o AIMDOptions) Option {
+	return func(options *Options) {
+		options.NewAlgorithm = func() Algorithm { return NewAIMD(o) }
+	}
+}
+
+// Gradient limits the concurrency with the gradient of the latency, this is the default
+func Gradient(o GradientOptions) Option {
+	return func(options *Options) {
+		options.NewAlgorithm = func() Algorithm { return NewGradient(o) }
+	}
+}
+
+// WithAlgorithm sets the algorithm created for each key
+func WithAlgorithm(newAlgorithm func() Algorithm) Option {
+	return func(options *Options) {
+		options.NewAlgorithm = newAlgorithm
+	}
+}
+
+func IsDrop(isDrop func(*goreq.Resp) bool) Option {
+	return func(options *Options) {
+		options.IsDrop = isDrop
+	}
+}
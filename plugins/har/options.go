@@ -0,0 +1,42 @@
+package har
+
+type Options struct {
+	RedactHeaders []string // headers whose values are replaced, cookies are redacted along with Cookie and Set-Cookie
+	MaxBodySize   int      // request and response bodies are truncated to this size, no body recorded if < 0
+	MaxEntries    int      // the oldest entries are dropped beyond this number, no limit if <= 0
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		RedactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+		MaxBodySize:   64 * 1024,
+		MaxEntries:    1000,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// RedactHeaders add headers to redact
+func RedactHeaders(headers ...string) Option {
+	return func(options *Options) {
+		options.RedactHeaders = append(options.RedactHeaders, headers...)
+	}
+}
+
+// MaxBodySize set max body size to record
+func MaxBodySize(size int) Option {
+	return func(options *Options) {
+		options.MaxBodySize = size
+	}
+}
+
+// MaxEntries set max entries kept by the recorder
+func MaxEntries(n int) Option {
+	return func(options *Options) {
+		options.MaxEntries = n
+	}
+}
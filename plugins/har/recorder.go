@@ -0,0 +1,367 @@
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aiscrm/goreq"
+)
+
+const redacted = "[REDACTED]"
+
+// Recorder records the exchanges passing through its handler into an HTTP Archive
+type Recorder struct {
+	options Options
+	redact  map[string]bool
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewRecorder(opts ...Option) *Recorder {
+	r := &Recorder{
+		options: newOptions(opts...),
+		redact:  make(map[string]bool),
+	}
+	for _, h := range r.options.RedactHeaders {
+		r.redact[http.CanonicalHeaderKey(h)] = true
+	}
+	return r
+}
+
+// Handler records every request passing through it, it should be used after handlers
+// changing the request, like auth, and before handlers reading the response body.
+func (r *Recorder) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		t := &tracer{}
+		reqCtx := ctx.Req.Context()
+		ctx.Req.WithContext(httptrace.WithClientTrace(reqCtx, t.clientTrace()))
+		t.start = time.Now()
+		ctx.Next()
+		ctx.Req.WithContext(reqCtx)
+		r.add(r.entry(ctx, t))
+	}
+}
+
+// HAR returns a snapshot of the archive
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	return &HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "goreq", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// WriteTo writes the archive as json to w
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the archive as json to the named file
+func (r *Recorder) WriteFile(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = r.WriteTo(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Reset drops all recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+func (r *Recorder) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	if r.options.MaxEntries > 0 && len(r.entries) > r.options.MaxEntries {
+		r.entries = append(r.entries[:0:0], r.entries[len(r.entries)-r.options.MaxEntries:]...)
+	}
+}
+
+func (r *Recorder) entry(ctx *goreq.Context, t *tracer) Entry {
+	entry := Entry{
+		StartedDateTime: t.start,
+		Request: Request{
+			Method:      ctx.Req.GetMethod(),
+			URL:         ctx.Req.GetURL(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			QueryString: []NameValue{},
+			HeadersSize: -1,
+		},
+		Response: Response{
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+	if request := ctx.Resp.Request(); request != nil {
+		entry.Request.URL = request.URL.String()
+		entry.Request.HTTPVersion = request.Proto
+		entry.Request.Headers = r.headers(request.Header)
+		entry.Request.Cookies = r.cookies(request.Cookies(), "Cookie")
+		entry.Request.QueryString = nameValues(request.URL.Query())
+	}
+	body := ctx.Req.GetBody()
+	entry.Request.BodySize = int64(len(body))
+	if len(body) > 0 {
+		contentType := ctx.Req.GetHeader().Get(goreq.ContentType)
+		text, _, comment := r.content(contentType, body, int64(len(body)))
+		entry.Request.PostData = &PostData{MimeType: contentType, Text: text, Comment: comment}
+	}
+
+	var receiveEnd time.Time
+	if response := ctx.Resp.Response(); response != nil {
+		data := r.peekBody(response)
+		receiveEnd = time.Now()
+		contentType := response.Header.Get(goreq.ContentType)
+		entry.Response.Status = response.StatusCode
+		entry.Response.StatusText = http.StatusText(response.StatusCode)
+		entry.Response.HTTPVersion = response.Proto
+		entry.Response.Headers = r.headers(response.Header)
+		entry.Response.Cookies = r.cookies(response.Cookies(), "Set-Cookie")
+		entry.Response.RedirectURL = response.Header.Get("Location")
+		entry.Response.BodySize = response.ContentLength
+		entry.Response.Content.Size = response.ContentLength
+		if response.ContentLength < 0 && len(data) <= r.options.MaxBodySize {
+			entry.Response.Content.Size = int64(len(data))
+		}
+		entry.Response.Content.MimeType = contentType
+		entry.Response.Content.Text, entry.Response.Content.Encoding, entry.Response.Content.Comment =
+			r.content(contentType, data, response.ContentLength)
+	}
+	if err := ctx.Resp.Error(); err != nil {
+		entry.Response.Comment = err.Error()
+	}
+	entry.Timings, entry.ServerIPAddress, entry.Connection = t.timings(receiveEnd)
+	entry.Time = ms(time.Since(t.start))
+	return entry
+}
+
+// peekBody reads at most MaxBodySize+1 bytes of the body and restores it for the caller
+func (r *Recorder) peekBody(response *http.Response) []byte {
+	if r.options.MaxBodySize < 0 || response.Body == nil || response.Body == http.NoBody {
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(response.Body, int64(r.options.MaxBodySize)+1))
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), response.Body), response.Body}
+	return data
+}
+
+// content returns the text to record, its encoding and a comment if truncated
+func (r *Recorder) content(contentType string, data []byte, size int64) (text, encoding, comment string) {
+	if r.options.MaxBodySize < 0 {
+		return "", "", ""
+	}
+	if len(data) > r.options.MaxBodySize {
+		data = data[:r.options.MaxBodySize]
+		comment = "truncated"
+		if size > 0 {
+			comment = "truncated from " + strconv.FormatInt(size, 10) + " bytes"
+		}
+	}
+	if isText(contentType) && utf8.Valid(data) {
+		return string(data), "", comment
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64", comment
+}
+
+func (r *Recorder) headers(h http.Header) []NameValue {
+	values := make([]NameValue, 0, len(h))
+	for _, k := range sortedKeys(h) {
+		for _, v := range h[k] {
+			if r.redact[k] {
+				v = redacted
+			}
+			values = append(values, NameValue{Name: k, Value: v})
+		}
+	}
+	return values
+}
+
+func (r *Recorder) cookies(cookies []*http.Cookie, header string) []Cookie {
+	result := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		if r.redact[header] {
+			cookie.Value = redacted
+		}
+		result = append(result, cookie)
+	}
+	return result
+}
+
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	remoteAddr   string
+	localAddr    string
+}
+
+func (t *tracer) set(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+				t.localAddr = info.Conn.LocalAddr().String()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+// timings computes the HAR timings, server ip and connection id
+func (t *tracer) timings(receiveEnd time.Time) (Timings, string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 0, Wait: 0, Receive: 0}
+	if !t.dnsStart.IsZero() && !t.dnsDone.IsZero() {
+		timings.DNS = ms(t.dnsDone.Sub(t.dnsStart))
+	}
+	if !t.connectStart.IsZero() && !t.connectDone.IsZero() {
+		timings.Connect = ms(t.connectDone.Sub(t.connectStart))
+	}
+	if !t.tlsStart.IsZero() && !t.tlsDone.IsZero() {
+		timings.SSL = ms(t.tlsDone.Sub(t.tlsStart))
+		// the connect time includes the ssl time
+		timings.Connect = ms(t.tlsDone.Sub(t.connectStart))
+	}
+	if !t.gotConn.IsZero() {
+		blocked := ms(t.gotConn.Sub(t.start))
+		if timings.DNS > 0 {
+			blocked -= timings.DNS
+		}
+		if timings.Connect > 0 {
+			blocked -= timings.Connect
+		}
+		if blocked >= 0 {
+			timings.Blocked = blocked
+		}
+		if !t.wroteRequest.IsZero() {
+			timings.Send = ms(t.wroteRequest.Sub(t.gotConn))
+		}
+	}
+	if !t.wroteRequest.IsZero() && !t.firstByte.IsZero() {
+		timings.Wait = ms(t.firstByte.Sub(t.wroteRequest))
+	}
+	if !t.firstByte.IsZero() && !receiveEnd.IsZero() {
+		timings.Receive = ms(receiveEnd.Sub(t.firstByte))
+	}
+	serverIP := t.remoteAddr
+	if i := strings.LastIndexByte(serverIP, ':'); i >= 0 {
+		serverIP = strings.Trim(serverIP[:i], "[]")
+	}
+	connection := ""
+	if t.localAddr != "" {
+		connection = t.localAddr[strings.LastIndexByte(t.localAddr, ':')+1:]
+	}
+	return timings, serverIP, connection
+}
+
+func isText(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "x-www-form-urlencoded", "yaml", "graphql"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func nameValues(values map[string][]string) []NameValue {
+	result := make([]NameValue, 0, len(values))
+	for _, k := range sortedKeys(values) {
+		for _, v := range values[k] {
+			result = append(result, NameValue{Name: k, Value: v})
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
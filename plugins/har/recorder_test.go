@@ -0,0 +1,182 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aiscrm/goreq"
+)
+
+func newServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret", Path: "/"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", r.URL.Query().Get("id"))
+		_, _ = w.Write([]byte(`{"name":"` + strings.Repeat("a", 20) + `"}`))
+	}))
+}
+
+func TestRecorder(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	recorder := NewRecorder(RedactHeaders("X-Api-Key"))
+	c := goreq.NewClient()
+	c.Use(recorder.Handler())
+	resp := c.Post(ts.URL+"/users").WithQueryParam("id", 1).
+		WithHeader("Authorization", "Bearer token").WithHeader("X-Api-Key", "key").WithHeader("Accept", "application/json").
+		WithHeader("Cookie", "session=secret").
+		WithContentType("application/json").WithBinaryBody([]byte(`{"id":1}`)).Do()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	// the body is still readable after the recording
+	if body := resp.String(); !strings.HasPrefix(body, `{"name"`) {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Request.Method != http.MethodPost || entry.Request.URL != ts.URL+"/users?id=1" ||
+		entry.Request.QueryString[0] != (NameValue{Name: "id", Value: "1"}) {
+		t.Errorf("unexpected request %+v", entry.Request)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"id":1}` || entry.Request.BodySize != 8 {
+		t.Errorf("unexpected post data %+v", entry.Request.PostData)
+	}
+	if entry.Response.Status != http.StatusOK || entry.Response.Content.MimeType != "application/json" ||
+		!strings.HasPrefix(entry.Response.Content.Text, `{"name"`) {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+	if entry.ServerIPAddress != "127.0.0.1" || entry.Timings.Wait < 0 {
+		t.Errorf("unexpected server %q and timings %+v", entry.ServerIPAddress, entry.Timings)
+	}
+
+	headers := map[string]string{}
+	for _, h := range append(entry.Request.Headers, entry.Response.Headers...) {
+		headers[h.Name] = h.Value
+	}
+	for _, name := range []string{"Authorization", "X-Api-Key", "Cookie", "Set-Cookie"} {
+		if headers[name] != redacted {
+			t.Errorf("expected %s to be redacted, got %q", name, headers[name])
+		}
+	}
+	if headers["Accept"] != "application/json" || headers["X-Request-Id"] != "1" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	for _, cookie := range append(entry.Request.Cookies, entry.Response.Cookies...) {
+		if cookie.Value != redacted {
+			t.Errorf("expected cookie %s to be redacted, got %q", cookie.Name, cookie.Value)
+		}
+	}
+}
+
+func TestRecorderTruncate(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	recorder := NewRecorder(MaxBodySize(10))
+	c := goreq.NewClient()
+	c.Use(recorder.Handler())
+	resp := c.Post(ts.URL).WithContentType("text/plain").WithBinaryBody([]byte(strings.Repeat("b", 30))).Do()
+	if body := resp.String(); len(body) != 31 {
+		t.Fatalf("expected the whole body to be read by the caller, got %q", body)
+	}
+	entry := recorder.HAR().Log.Entries[0]
+	if entry.Request.PostData.Text != strings.Repeat("b", 10) || entry.Request.PostData.Comment != "truncated from 30 bytes" {
+		t.Errorf("unexpected post data %+v", entry.Request.PostData)
+	}
+	if content := entry.Response.Content; content.Text != `{"name":"a` || content.Comment != "truncated from 31 bytes" || content.Size != 31 {
+		t.Errorf("unexpected content %+v", content)
+	}
+
+	recorder = NewRecorder(MaxBodySize(-1))
+	c = goreq.NewClient()
+	c.Use(recorder.Handler())
+	c.Get(ts.URL).Do()
+	if content := recorder.HAR().Log.Entries[0].Response.Content; content.Text != "" {
+		t.Errorf("expected no body to be recorded, got %q", content.Text)
+	}
+}
+
+func TestRecorderConcurrent(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	recorder := NewRecorder(MaxEntries(15))
+	c := goreq.NewClient()
+	c.Use(recorder.Handler())
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Get(ts.URL).WithQueryParam("id", i).Do()
+		}(i)
+	}
+	wg.Wait()
+	entries := recorder.HAR().Log.Entries
+	if len(entries) != 15 {
+		t.Fatalf("expected the last 15 entries, got %d", len(entries))
+	}
+	ids := map[string]bool{}
+	for _, entry := range entries {
+		ids[entry.Request.QueryString[0].Value] = true
+	}
+	if len(ids) != 15 {
+		t.Errorf("expected distinct entries, got %v", ids)
+	}
+	recorder.Reset()
+	if len(recorder.HAR().Log.Entries) != 0 {
+		t.Error("expected no entry after reset")
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	recorder := NewRecorder()
+	c := goreq.NewClient()
+	c.Use(recorder.Handler())
+	for i := 0; i < 2; i++ {
+		c.Get(ts.URL).WithQueryParam("id", i).Do()
+	}
+	var buf bytes.Buffer
+	n, err := recorder.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("unexpected write of %d bytes: %v", n, err)
+	}
+
+	var raw map[string]map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"version", "creator", "entries"} {
+		if _, ok := raw["log"][key]; !ok {
+			t.Errorf("missing log.%s", key)
+		}
+	}
+	var archive HAR
+	if err := json.Unmarshal(buf.Bytes(), &archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Log.Version != "1.2" || len(archive.Log.Entries) != 2 {
+		t.Fatalf("unexpected archive %+v", archive.Log)
+	}
+	for i, entry := range archive.Log.Entries {
+		if entry.Request.URL != ts.URL+"?id="+strconv.Itoa(i) || entry.Response.Status != http.StatusOK ||
+			!entry.StartedDateTime.Equal(recorder.HAR().Log.Entries[i].StartedDateTime) {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	}
+}
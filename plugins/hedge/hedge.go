@@ -0,0 +1,258 @@
+package hedge
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// Stats of a Hedger
+type Stats struct {
+	Requests int64 // requests which could be hedged
+	Hedges   int64 // hedged attempts sent
+	Wins     int64 // requests answered by a hedged attempt
+}
+
+// Hedger sends hedged attempts of slow requests, and keeps the first successful response
+type Hedger struct {
+	options Options
+	mu      sync.Mutex
+	keys    map[string]*latencies
+	tokens  float64
+	stats   Stats
+}
+
+func New(opts ...Option) *Hedger {
+	options := newOptions(opts...)
+	return &Hedger{
+		options: options,
+		keys:    make(map[string]*latencies),
+		tokens:  options.BudgetBurst,
+	}
+}
+
+// Hedge returns a handler sending another attempt of a request when no response arrived within the delay
+func Hedge(opts ...Option) goreq.HandlerFunc {
+	return New(opts...).Handler()
+}
+
+type attempt struct {
+	n      int
+	ctx    *goreq.Context
+	cancel context.CancelFunc
+}
+
+// Handler runs each attempt on a copy of the context, with its own cancelable request context,
+// then copies the response of the winner and aborts the chain.
+func (h *Hedger) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		if h.options.MaxAttempts < 2 || !h.options.ShouldHedge(ctx.Req) {
+			ctx.Next()
+			return
+		}
+		key := h.options.KeyFunc(ctx.Req)
+		delay := h.delay(key)
+		h.deposit()
+
+		parent := ctx.Req.Context()
+		results := make(chan *attempt, h.options.MaxAttempts)
+		var attempts []*attempt
+		launch := func() {
+			attemptCtx, cancel := context.WithCancel(parent)
+			a := &attempt{n: len(attempts), ctx: ctx.Copy(), cancel: cancel}
+			a.ctx.Req.WithContext(attemptCtx)
+			attempts = append(attempts, a)
+			go func() {
+				a.ctx.Next()
+				results <- a
+			}()
+		}
+		launch()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var winner *attempt
+		maxAttempts, pending := h.options.MaxAttempts, 1
+		for winner == nil {
+			var hedge <-chan time.Time
+			if len(attempts) < maxAttempts {
+				hedge = timer.C
+			}
+			select {
+			case <-hedge:
+				if !h.withdraw() {
+					// out of budget, wait for the attempts already sent
+					maxAttempts = len(attempts)
+					continue
+				}
+				launch()
+				pending++
+				timer.Reset(delay)
+			case a := <-results:
+				pending--
+				// hedging is no retry, the last failure is kept when no attempt is left
+				if succeeded(a.ctx.Resp) || pending == 0 {
+					winner = a
+					break
+				}
+				closeBody(a.ctx.Resp)
+				a.cancel()
+			}
+		}
+
+		// the losers in flight are canceled, and their responses closed once they return
+		for _, a := range attempts {
+			if a != winner {
+				a.cancel()
+			}
+		}
+		if pending > 0 {
+			go func(n int) {
+				for ; n > 0; n-- {
+					closeBody((<-results).ctx.Resp)
+				}
+			}(pending)
+		}
+
+		// the context of the winner lives until its body is closed
+		if response := winner.ctx.Resp.Response(); response != nil && response.Body != nil {
+			response.Body = &cancelBody{ReadCloser: response.Body, cancel: winner.cancel}
+		} else {
+			winner.cancel()
+		}
+		*ctx.Resp = *winner.ctx.Resp
+		h.record(key, winner)
+		if h.options.OnWin != nil {
+			h.options.OnWin(ctx.Req, winner.n)
+		}
+		ctx.Abort()
+	}
+}
+
+// Stats returns the hedging counters
+func (h *Hedger) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// deposit adds the share of a request to the budget
+func (h *Hedger) deposit() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.Requests++
+	h.tokens += h.options.BudgetRatio
+	if h.tokens > h.options.BudgetBurst {
+		h.tokens = h.options.BudgetBurst
+	}
+}
+
+// withdraw takes a hedged attempt from the budget
+func (h *Hedger) withdraw() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	h.stats.Hedges++
+	return true
+}
+
+func (h *Hedger) record(key string, winner *attempt) {
+	if winner.n > 0 {
+		h.mu.Lock()
+		h.stats.Wins++
+		h.mu.Unlock()
+	}
+	if h.options.Percentile > 0 && succeeded(winner.ctx.Resp) {
+		h.latencies(key).add(winner.ctx.Resp.Cost())
+	}
+}
+
+// delay returns the configured delay, or the percentile of the latencies of key once enough are known
+func (h *Hedger) delay(key string) time.Duration {
+	if h.options.Percentile <= 0 {
+		return h.options.Delay
+	}
+	if d, ok := h.latencies(key).percentile(h.options.Percentile, h.options.MinSamples); ok {
+		return d
+	}
+	return h.options.Delay
+}
+
+func (h *Hedger) latencies(key string) *latencies {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.keys[key]
+	if !ok {
+		l = &latencies{samples: make([]time.Duration, 0, h.options.Samples)}
+		h.keys[key] = l
+	}
+	return l
+}
+
+// latencies keeps the last latencies of a key in a ring
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < cap(l.samples) {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % len(l.samples)
+}
+
+func (l *latencies) percentile(p float64, minSamples int) (time.Duration, bool) {
+	l.mu.Lock()
+	if len(l.samples) == 0 || len(l.samples) < minSamples {
+		l.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
+
+func succeeded(resp *goreq.Resp) bool {
+	return resp.Error() == nil && resp.StatusCode() < http.StatusInternalServerError
+}
+
+func closeBody(resp *goreq.Resp) {
+	if response := resp.Response(); response != nil && response.Body != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+		_ = response.Body.Close()
+	}
+}
+
+// cancelBody cancels the context of the winning attempt when its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,65 @@
+package hedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestHedge(t *testing.T) {
+	var requests int32
+	canceled := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			<-r.Context().Done()
+			close(canceled)
+			return
+		}
+		_, _ = w.Write([]byte("hedged"))
+	}))
+	defer ts.Close()
+
+	won := -1
+	hedger := New(Delay(20*time.Millisecond), OnWin(func(r *goreq.Req, attempt int) { won = attempt }))
+	c := goreq.NewClient()
+	c.Use(hedger.Handler())
+
+	resp := c.Get(ts.URL).Do()
+	if body, err := resp.AsString(); err != nil || body != "hedged" {
+		t.Fatalf("expected the hedged response, got %q, %v", body, err)
+	}
+	if won != 1 {
+		t.Errorf("expected the second attempt to win, got %d", won)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected the first attempt to be canceled")
+	}
+	if stats := hedger.Stats(); stats != (Stats{Requests: 1, Hedges: 1, Wins: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// writes are not hedged
+	c.Post(ts.URL).Do()
+	if stats := hedger.Stats(); stats.Requests != 1 {
+		t.Errorf("expected the post not to be hedged, got %+v", stats)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	l := &latencies{samples: make([]time.Duration, 0, 10)}
+	for i := 1; i <= 20; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	if _, ok := l.percentile(0.9, 11); ok {
+		t.Fatal("expected too few samples")
+	}
+	if d, _ := l.percentile(0.9, 10); d != 19*time.Millisecond {
+		t.Fatalf("expected the p90 of the last 10 samples, got %s", d)
+	}
+}
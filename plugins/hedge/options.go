@@ -0,0 +1,114 @@
+package hedge
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+type Options struct {
+	KeyFunc     func(*goreq.Req) string
+	ShouldHedge func(*goreq.Req) bool // whether a request may be sent several times
+	Delay       time.Duration         // delay before a hedged attempt, until enough latencies are known when Percentile is set
+	Percentile  float64               // percentile of the recent latencies of the key used as delay, disabled if <= 0
+	Samples     int                   // latencies kept per key
+	MinSamples  int                   // latencies needed before the percentile is used
+	MaxAttempts int                   // attempts of a request, including the first one
+	BudgetRatio float64               // hedged attempts allowed per request, to cap the extra load
+	BudgetBurst float64               // hedged attempts allowed at once when the budget is full
+	OnWin       func(r *goreq.Req, attempt int)
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc:     ByHost,
+		ShouldHedge: DefaultShouldHedge,
+		Delay:       100 * time.Millisecond,
+		Samples:     100,
+		MinSamples:  20,
+		MaxAttempts: 2,
+		BudgetRatio: 0.1,
+		BudgetBurst: 10,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 1
+	}
+	if options.Samples < 1 {
+		options.Samples = 1
+	}
+	return options
+}
+
+// ByHost learns latencies per host
+func ByHost(r *goreq.Req) string {
+	return r.GetHost()
+}
+
+// ByName learns latencies per request name
+func ByName(r *goreq.Req) string {
+	return r.GetName()
+}
+
+// DefaultShouldHedge hedges the idempotent reads only
+func DefaultShouldHedge(r *goreq.Req) bool {
+	switch r.GetMethod() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func KeyFunc(keyFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+func ShouldHedge(shouldHedge func(*goreq.Req) bool) Option {
+	return func(options *Options) {
+		options.ShouldHedge = shouldHedge
+	}
+}
+
+// Delay sets a fixed delay before each hedged attempt
+func Delay(delay time.Duration) Option {
+	return func(options *Options) {
+		options.Delay = delay
+	}
+}
+
+// Percentile learns the delay from the latencies of the last samples requests of a key, like 0.95 for p95
+func Percentile(percentile float64, samples, minSamples int) Option {
+	return func(options *Options) {
+		options.Percentile = percentile
+		options.Samples = samples
+		options.MinSamples = minSamples
+	}
+}
+
+func MaxAttempts(maxAttempts int) Option {
+	return func(options *Options) {
+		options.MaxAttempts = maxAttempts
+	}
+}
+
+// Budget allows ratio hedged attempts per request, and up to burst at once
+func Budget(ratio, burst float64) Option {
+	return func(options *Options) {
+		options.BudgetRatio = ratio
+		options.BudgetBurst = burst
+	}
+}
+
+// OnWin is called with the attempt which response is kept, 0 being the first one
+func OnWin(onWin func(r *goreq.Req, attempt int)) Option {
+	return func(options *Options) {
+		options.OnWin = onWin
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/aiscrm/goreq/plugins/otelmetrics
+
+go 1.24
+
+require (
+	github.com/aiscrm/goreq v0.3.4-0.20261019100825-183e13ee6835
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/metric v1.24.0
+	go.opentelemetry.io/otel/sdk/metric v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/sdk v1.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
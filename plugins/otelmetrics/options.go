@@ -0,0 +1,53 @@
+package otelmetrics
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type Options struct {
+	MeterProvider   metric.MeterProvider
+	DurationBuckets []float64 // bucket boundaries of the duration histogram, in seconds
+	SizeBuckets     []float64 // bucket boundaries of the body size histograms, in bytes
+	RouteName       bool      // record Req.GetName() as the url.template attribute
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		MeterProvider:   otel.GetMeterProvider(),
+		DurationBuckets: []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10},
+		SizeBuckets:     []float64{0, 100, 1000, 10000, 100000, 1000000, 10000000},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func MeterProvider(provider metric.MeterProvider) Option {
+	return func(options *Options) {
+		options.MeterProvider = provider
+	}
+}
+
+func DurationBuckets(buckets ...float64) Option {
+	return func(options *Options) {
+		options.DurationBuckets = buckets
+	}
+}
+
+func SizeBuckets(buckets ...float64) Option {
+	return func(options *Options) {
+		options.SizeBuckets = buckets
+	}
+}
+
+// RouteName records the request name, a route template like "/users/{id}", as an attribute.
+// The name must have a low cardinality.
+func RouteName(enable bool) Option {
+	return func(options *Options) {
+		options.RouteName = enable
+	}
+}
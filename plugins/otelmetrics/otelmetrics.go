@@ -0,0 +1,114 @@
+package otelmetrics
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aiscrm/goreq"
+)
+
+const instrumentationName = "github.com/aiscrm/goreq/plugins/otelmetrics"
+
+// attributes of the OpenTelemetry HTTP client semantic conventions
+const (
+	attrHTTPRequestMethod      = attribute.Key("http.request.method")
+	attrHTTPResponseStatusCode = attribute.Key("http.response.status_code")
+	attrServerAddress          = attribute.Key("server.address")
+	attrServerPort             = attribute.Key("server.port")
+	attrURLTemplate            = attribute.Key("url.template")
+	attrErrorType              = attribute.Key("error.type")
+)
+
+// Metrics records the OpenTelemetry HTTP client metrics:
+// http.client.request.duration, http.client.request.body.size,
+// http.client.response.body.size and http.client.active_requests.
+func Metrics(opts ...Option) goreq.HandlerFunc {
+	options := newOptions(opts...)
+	meter := options.MeterProvider.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("http.client.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP client requests."),
+		metric.WithExplicitBucketBoundaries(options.DurationBuckets...),
+	)
+	handleErr(err)
+	requestSize, err := meter.Int64Histogram("http.client.request.body.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP client request bodies."),
+		metric.WithExplicitBucketBoundaries(options.SizeBuckets...),
+	)
+	handleErr(err)
+	responseSize, err := meter.Int64Histogram("http.client.response.body.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP client response bodies."),
+		metric.WithExplicitBucketBoundaries(options.SizeBuckets...),
+	)
+	handleErr(err)
+	activeRequests, err := meter.Int64UpDownCounter("http.client.active_requests",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of active HTTP requests."),
+	)
+	handleErr(err)
+
+	return func(ctx *goreq.Context) {
+		reqCtx := ctx.Req.Context()
+		attrs := []attribute.KeyValue{attrHTTPRequestMethod.String(ctx.Req.GetMethod())}
+		if u, err := url.Parse(ctx.Req.GetURL()); err == nil && u.Host != "" {
+			attrs = append(attrs, serverAttributes(u)...)
+		}
+		active := metric.WithAttributes(attrs...)
+		activeRequests.Add(reqCtx, 1, active)
+		defer activeRequests.Add(reqCtx, -1, active)
+		begin := time.Now()
+		ctx.Next()
+		elapsed := time.Since(begin)
+
+		if request := ctx.Resp.Request(); request != nil && request.URL != nil && request.URL.Host != "" {
+			// the built url includes the client's prefix path
+			attrs = append([]attribute.KeyValue{attrs[0]}, serverAttributes(request.URL)...)
+		}
+		if options.RouteName && ctx.Req.GetName() != "" {
+			attrs = append(attrs, attrURLTemplate.String(ctx.Req.GetName()))
+		}
+		if code := ctx.Resp.StatusCode(); code > 0 {
+			attrs = append(attrs, attrHTTPResponseStatusCode.Int(code))
+		}
+		if err := ctx.Resp.Error(); err != nil {
+			attrs = append(attrs, attrErrorType.String(goreq.ErrorKind(err)))
+		} else if code := ctx.Resp.StatusCode(); code >= 400 {
+			attrs = append(attrs, attrErrorType.String(strconv.Itoa(code)))
+		}
+		set := metric.WithAttributes(attrs...)
+		duration.Record(reqCtx, elapsed.Seconds(), set)
+		requestSize.Record(reqCtx, int64(len(ctx.Req.GetBody())), set)
+		if size := ctx.Resp.ContentLength(); size >= 0 && ctx.Resp.Response() != nil {
+			responseSize.Record(reqCtx, size, set)
+		}
+	}
+}
+
+func serverAttributes(u *url.URL) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attrServerAddress.String(u.Hostname())}
+	port := u.Port()
+	switch {
+	case port != "":
+	case u.Scheme == "https":
+		port = "443"
+	case u.Scheme == "http":
+		port = "80"
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attrServerPort.Int(p))
+	}
+	return attrs
+}
+
+func handleErr(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
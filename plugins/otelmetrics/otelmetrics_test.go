@@ -0,0 +1,86 @@
+package otelmetrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/aiscrm/goreq"
+)
+
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func value(attrs attribute.Set, key attribute.Key) string {
+	v, _ := attrs.Value(key)
+	return v.Emit()
+}
+
+func TestMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("unavailable"))
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	reader := sdkmetric.NewManualReader()
+	c := goreq.NewClient()
+	c.Use(Metrics(MeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))), RouteName(true)))
+	c.Post(ts.URL + "/users").WithName("/users").WithBody("{}").Do()
+	c.Get(closed.URL).Do()
+
+	metrics := collect(t, reader)
+	for _, name := range []string{"http.client.request.duration", "http.client.request.body.size",
+		"http.client.response.body.size", "http.client.active_requests"} {
+		if metrics[name] == nil {
+			t.Errorf("missing instrument %s", name)
+		}
+	}
+
+	duration, ok := metrics["http.client.request.duration"].(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 2 {
+		t.Fatalf("expected a duration data point per request, got %+v", metrics["http.client.request.duration"])
+	}
+	for _, dp := range duration.DataPoints {
+		switch value(dp.Attributes, attrHTTPRequestMethod) {
+		case http.MethodPost:
+			if value(dp.Attributes, attrHTTPResponseStatusCode) != "503" || value(dp.Attributes, attrErrorType) != "503" ||
+				value(dp.Attributes, attrServerAddress) != "127.0.0.1" || value(dp.Attributes, attrURLTemplate) != "/users" {
+				t.Errorf("unexpected attributes %v", dp.Attributes.ToSlice())
+			}
+		case http.MethodGet:
+			if value(dp.Attributes, attrErrorType) != goreq.ErrorKindConnection || dp.Attributes.HasValue(attrHTTPResponseStatusCode) {
+				t.Errorf("unexpected attributes %v", dp.Attributes.ToSlice())
+			}
+		}
+	}
+
+	responseSize := metrics["http.client.response.body.size"].(metricdata.Histogram[int64])
+	if len(responseSize.DataPoints) != 1 || responseSize.DataPoints[0].Sum != int64(len("unavailable")) {
+		t.Errorf("expected the size of the only response, got %+v", responseSize.DataPoints)
+	}
+	active := metrics["http.client.active_requests"].(metricdata.Sum[int64])
+	for _, dp := range active.DataPoints {
+		if dp.Value != 0 {
+			t.Errorf("expected no active request, got %d for %v", dp.Value, dp.Attributes.ToSlice())
+		}
+	}
+}
@@ -1,16 +1,16 @@
 module github.com/aiscrm/goreq/plugins/prometheus
 
-go 1.19
+go 1.24
 
 require (
-	github.com/aiscrm/goreq v0.3.3
+	github.com/aiscrm/goreq v0.3.4-0.20261019100825-183e13ee6835
 	github.com/prometheus/client_golang v1.19.0
+	github.com/prometheus/client_model v0.5.0
 )
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
-	github.com/prometheus/client_model v0.5.0 // indirect
 	github.com/prometheus/common v0.48.0 // indirect
 	github.com/prometheus/procfs v0.12.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
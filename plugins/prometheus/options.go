@@ -1,22 +1,31 @@
 package prometheus
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/aiscrm/goreq"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type Options struct {
-	NameSpace  string
-	Registerer prometheus.Registerer
-	Gatherer   prometheus.Gatherer
-	Objectives map[float64]float64
+	NameSpace   string
+	Registerer  prometheus.Registerer
+	Gatherer    prometheus.Gatherer
+	Objectives  map[float64]float64 // objectives of the latency_milliseconds summary, nil disables it
+	Buckets     []float64           // buckets of the latency histogram, in seconds
+	SizeBuckets []float64           // buckets of the request and response size histograms, in bytes
+	LabelFunc   func(*goreq.Req) string
 }
 
 type Option func(*Options)
 
 func newOptions(opts ...Option) Options {
 	options := Options{
-		NameSpace:  "goreq",
-		Registerer: prometheus.DefaultRegisterer,
-		Gatherer:   prometheus.DefaultGatherer,
-		Objectives: map[float64]float64{0.0: 0, 0.5: 0.05, 0.75: 0.04, 0.90: 0.03, 0.95: 0.02, 0.98: 0.001, 1: 0},
+		NameSpace:   "goreq",
+		Registerer:  prometheus.DefaultRegisterer,
+		Gatherer:    prometheus.DefaultGatherer,
+		Objectives:  map[float64]float64{0.0: 0, 0.5: 0.05, 0.75: 0.04, 0.90: 0.03, 0.95: 0.02, 0.98: 0.001, 1: 0},
+		Buckets:     prometheus.DefBuckets,
+		SizeBuckets: prometheus.ExponentialBuckets(100, 10, 6),
+		LabelFunc:   defaultLabelFunc,
 	}
 	for _, opt := range opts {
 		opt(&options)
@@ -24,6 +33,15 @@ func newOptions(opts ...Option) Options {
 	return options
 }
 
+// defaultLabelFunc uses the request name, requests without name share the same label
+// so that raw urls never end up in labels.
+func defaultLabelFunc(r *goreq.Req) string {
+	if r.GetName() == "" {
+		return "other"
+	}
+	return r.GetName()
+}
+
 func NameSpace(nameSpace string) Option {
 	return func(options *Options) {
 		options.NameSpace = nameSpace
@@ -42,8 +60,29 @@ func Gatherer(gatherer prometheus.Gatherer) Option {
 	}
 }
 
+// Objectives sets the objectives of the latency_milliseconds summary, kept for the existing dashboards.
+// The request_duration_seconds histogram supersedes it, nil objectives disable it.
 func Objectives(objectives map[float64]float64) Option {
 	return func(options *Options) {
 		options.Objectives = objectives
 	}
 }
+
+func Buckets(buckets ...float64) Option {
+	return func(options *Options) {
+		options.Buckets = buckets
+	}
+}
+
+func SizeBuckets(buckets ...float64) Option {
+	return func(options *Options) {
+		options.SizeBuckets = buckets
+	}
+}
+
+// LabelFunc sets the function returning the route label of a request, its values must have a low cardinality
+func LabelFunc(labelFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.LabelFunc = labelFunc
+	}
+}
@@ -1,6 +1,9 @@
 package prometheus
 
 import (
+	"errors"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/aiscrm/goreq"
@@ -8,40 +11,146 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Prometheus records the requests_total counter, the request_duration_seconds histogram,
+// the requests_in_flight gauge and the request_size_bytes and response_size_bytes histograms,
+// labeled by host and route. The request_total counter and the latency_milliseconds summary
+// of the previous versions keep their host, uri and status labels for the existing dashboards,
+// the summary is disabled by nil Objectives.
 func Prometheus(opts ...Option) goreq.HandlerFunc {
 	options := newOptions(opts...)
-	counter := prometheus.NewCounterVec(
+	labels := []string{"host", "route", "method", "status", "error"}
+	counter := register(options.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: options.NameSpace,
+			Name:      "requests_total",
+			Help:      "Requests processed, partitioned by host, route, method, status and error kind",
+		},
+		labels,
+	))
+	legacyCounter := register(options.Registerer, prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: options.NameSpace,
 			Name:      "request_total",
 			Help:      "Requests processed, partitioned by host, uri and status",
 		},
-		[]string{
-			"host",
-			"uri",
-			"status",
+		[]string{"host", "uri", "status"},
+	))
+	latency := register(options.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.NameSpace,
+			Name:      "request_duration_seconds",
+			Help:      "Request latencies in seconds, partitioned by host, route, method, status and error kind",
+			Buckets:   options.Buckets,
 		},
-	)
-	summary := prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Namespace:  options.NameSpace,
-			Name:       "latency_milliseconds",
-			Objectives: options.Objectives,
-			Help:       "Request latencies in milliseconds, partitioned by host and uri",
+		labels,
+	))
+	inFlight := register(options.Registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: options.NameSpace,
+			Name:      "requests_in_flight",
+			Help:      "Requests in flight, partitioned by host and route",
 		},
-		[]string{
-			"host",
-			"uri",
+		[]string{"host", "route"},
+	))
+	requestSize := register(options.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.NameSpace,
+			Name:      "request_size_bytes",
+			Help:      "Request body sizes in bytes, partitioned by host, route and method",
+			Buckets:   options.SizeBuckets,
 		},
-	)
-	options.Registerer.MustRegister(counter)
-	options.Registerer.MustRegister(summary)
+		[]string{"host", "route", "method"},
+	))
+	responseSize := register(options.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: options.NameSpace,
+			Name:      "response_size_bytes",
+			Help:      "Response body sizes in bytes, partitioned by host, route, method and status",
+			Buckets:   options.SizeBuckets,
+		},
+		[]string{"host", "route", "method", "status"},
+	))
+	var summary *prometheus.SummaryVec
+	if options.Objectives != nil {
+		summary = register(options.Registerer, prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace:  options.NameSpace,
+				Name:       "latency_milliseconds",
+				Help:       "Request latencies in milliseconds, partitioned by host and uri",
+				Objectives: options.Objectives,
+			},
+			[]string{"host", "uri"},
+		))
+	}
 	return func(ctx *goreq.Context) {
+		host := requestHost(ctx.Req)
+		route := options.LabelFunc(ctx.Req)
+		method := ctx.Req.GetMethod()
+		gauge := inFlight.WithLabelValues(host, route)
+		gauge.Inc()
+		defer gauge.Dec()
 		begin := time.Now()
 		ctx.Next()
 		d := time.Since(begin)
-		summary.WithLabelValues(ctx.Resp.Request().RequestURI).Observe(float64(d.Milliseconds()))
-		counter.WithLabelValues(ctx.Resp.Request().URL.Host, ctx.Resp.Request().URL.Path, ctx.Resp.Response().Status).Inc()
+
+		status := ""
+		if code := ctx.Resp.StatusCode(); code > 0 {
+			status = strconv.Itoa(code)
+		}
+		errorKind := goreq.ErrorKind(ctx.Resp.Error())
+		latency.WithLabelValues(host, route, method, status, errorKind).Observe(d.Seconds())
+		counter.WithLabelValues(host, route, method, status, errorKind).Inc()
+		legacyHost, uri, legacyStatus := legacyLabels(ctx)
+		if summary != nil {
+			summary.WithLabelValues(legacyHost, uri).Observe(float64(d.Milliseconds()))
+		}
+		legacyCounter.WithLabelValues(legacyHost, uri, legacyStatus).Inc()
+		requestSize.WithLabelValues(host, route, method).Observe(float64(len(ctx.Req.GetBody())))
+		if ctx.Resp.Response() != nil && ctx.Resp.ContentLength() >= 0 {
+			responseSize.WithLabelValues(host, route, method, status).Observe(float64(ctx.Resp.ContentLength()))
+		}
+	}
+}
+
+// requestHost returns the host of the request, including the client's prefix path
+func requestHost(r *goreq.Req) string {
+	if host := r.GetHost(); host != "" {
+		return host
+	}
+	if r.GetClient() == nil {
+		return ""
+	}
+	u, err := url.Parse(r.GetClient().Options().PrefixPath)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// legacyLabels returns the labels of the metrics of the previous versions,
+// the host and the path of the url which was sent, and the status line like "200 OK"
+func legacyLabels(ctx *goreq.Context) (host, uri, status string) {
+	if request := ctx.Resp.Request(); request != nil && request.URL != nil {
+		host, uri = request.URL.Host, request.URL.Path
+	} else {
+		host, uri = requestHost(ctx.Req), ctx.Req.GetPath()
+	}
+	if response := ctx.Resp.Response(); response != nil {
+		status = response.Status
+	}
+	return host, uri, status
+}
+
+// register registers c, or returns the collector already registered by another client
+func register[T prometheus.Collector](registerer prometheus.Registerer, c T) T {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
 	}
-	return nil
+	return c
 }
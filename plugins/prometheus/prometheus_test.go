@@ -0,0 +1,148 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aiscrm/goreq"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gather(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		metrics[f.GetName()] = f
+	}
+	return metrics
+}
+
+func labels(m *dto.Metric) map[string]string {
+	values := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		values[l.GetName()] = l.GetValue()
+	}
+	return values
+}
+
+func TestPrometheus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("bad gateway"))
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	registry := prometheus.NewRegistry()
+	opts := []Option{Registerer(registry), Gatherer(registry), Buckets(0.1, 1)}
+	c := goreq.NewClient()
+	c.Use(Prometheus(opts...))
+	// a second client registering the same metrics shares the collectors instead of panicking
+	c2 := goreq.NewClient()
+	c2.Use(Prometheus(opts...))
+
+	c.Get(ts.URL + "/users/1").WithName("/users/{id}").Do()
+	c2.Get(ts.URL + "/users/2").WithName("/users/{id}").Do()
+	// transport errors have no response
+	c.Post(closed.URL + "/users").WithBody("{}").Do()
+
+	metrics := gather(t, registry)
+	for _, name := range []string{"goreq_requests_total", "goreq_request_total", "goreq_request_duration_seconds", "goreq_requests_in_flight",
+		"goreq_request_size_bytes", "goreq_response_size_bytes", "goreq_latency_milliseconds"} {
+		if metrics[name] == nil {
+			t.Errorf("missing metric %s", name)
+		}
+	}
+
+	counters := metrics["goreq_requests_total"].GetMetric()
+	if len(counters) != 2 {
+		t.Fatalf("expected a series per route, got %d", len(counters))
+	}
+	for _, m := range counters {
+		l := labels(m)
+		switch l["route"] {
+		case "/users/{id}":
+			if l["host"] != ts.Listener.Addr().String() || l["method"] != http.MethodGet || l["status"] != "502" ||
+				l["error"] != "" || m.GetCounter().GetValue() != 2 {
+				t.Errorf("unexpected series %v = %v", l, m.GetCounter().GetValue())
+			}
+		case "other":
+			if l["method"] != http.MethodPost || l["status"] != "" || l["error"] != goreq.ErrorKindConnection {
+				t.Errorf("unexpected series %v", l)
+			}
+		default:
+			t.Errorf("unexpected route %q", l["route"])
+		}
+	}
+
+	// the metrics of the previous versions keep their labels
+	legacy := map[string]float64{}
+	for _, m := range metrics["goreq_request_total"].GetMetric() {
+		l := labels(m)
+		if len(l) != 3 {
+			t.Errorf("unexpected labels %v", l)
+		}
+		legacy[l["host"]+" "+l["uri"]+" "+l["status"]] = m.GetCounter().GetValue()
+	}
+	host := ts.Listener.Addr().String()
+	want := map[string]float64{
+		host + " /users/1 502 Bad Gateway":           1,
+		host + " /users/2 502 Bad Gateway":           1,
+		closed.Listener.Addr().String() + " /users ": 1,
+	}
+	if len(legacy) != len(want) {
+		t.Errorf("unexpected legacy series %v", legacy)
+	}
+	for series, value := range want {
+		if legacy[series] != value {
+			t.Errorf("expected %q = %v, got %v", series, value, legacy)
+		}
+	}
+	for _, m := range metrics["goreq_latency_milliseconds"].GetMetric() {
+		if l := labels(m); len(l) != 2 || l["host"] == "" || l["uri"] == "" {
+			t.Errorf("unexpected summary labels %v", l)
+		}
+	}
+
+	for _, m := range metrics["goreq_request_duration_seconds"].GetMetric() {
+		buckets := m.GetHistogram().GetBucket()
+		if len(buckets) != 2 || buckets[0].GetUpperBound() != 0.1 || buckets[1].GetUpperBound() != 1 {
+			t.Errorf("unexpected buckets %v", buckets)
+		}
+	}
+	for _, m := range metrics["goreq_requests_in_flight"].GetMetric() {
+		if m.GetGauge().GetValue() != 0 {
+			t.Errorf("expected no request in flight, got %v", m.GetGauge().GetValue())
+		}
+	}
+	responses := metrics["goreq_response_size_bytes"].GetMetric()
+	if len(responses) != 1 || responses[0].GetHistogram().GetSampleSum() != 2*float64(len("bad gateway")) {
+		t.Errorf("expected the sizes of the responses only, got %v", responses)
+	}
+}
+
+func TestLabelFunc(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	c := goreq.NewClient()
+	c.Use(Prometheus(Registerer(registry), Objectives(nil), LabelFunc(func(r *goreq.Req) string {
+		return "custom"
+	})))
+	c.Get(ts.URL).Do()
+
+	metrics := gather(t, registry)
+	if metrics["goreq_latency_milliseconds"] != nil {
+		t.Error("nil objectives must disable the summary")
+	}
+	if route := labels(metrics["goreq_requests_total"].GetMetric()[0])["route"]; route != "custom" {
+		t.Errorf("expected the label of the label func, got %q", route)
+	}
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// Limit of a token bucket
+type Limit struct {
+	Rate  float64 // tokens added per second
+	Burst int     // size of the bucket
+}
+
+type Options struct {
+	KeyFunc func(*goreq.Req) string
+	Limit   Limit            // limit of the keys not in Limits
+	Limits  map[string]Limit // limits per key
+	Wait    bool             // wait for a token, or fail fast with a LimitError
+	MaxWait time.Duration    // longest wait before failing with a LimitError, no limit if <= 0
+	Store   Store
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc: ByHost,
+		Limit:   Limit{Rate: 10, Burst: 10},
+		Limits:  make(map[string]Limit),
+		Wait:    true,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Store == nil {
+		options.Store = NewMemoryStore()
+	}
+	return options
+}
+
+// ByHost limits requests per host
+func ByHost(r *goreq.Req) string {
+	return r.GetHost()
+}
+
+// ByName limits requests per request name
+func ByName(r *goreq.Req) string {
+	return r.GetName()
+}
+
+func KeyFunc(keyFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+// Rate sets the default limit, rate requests per second with bursts of burst requests
+func Rate(rate float64, burst int) Option {
+	return func(options *Options) {
+		options.Limit = Limit{Rate: rate, Burst: burst}
+	}
+}
+
+// KeyRate sets the limit of key
+func KeyRate(key string, rate float64, burst int) Option {
+	return func(options *Options) {
+		options.Limits[key] = Limit{Rate: rate, Burst: burst}
+	}
+}
+
+// Wait sets whether requests wait for a token or fail fast
+func Wait(wait bool) Option {
+	return func(options *Options) {
+		options.Wait = wait
+	}
+}
+
+func MaxWait(maxWait time.Duration) Option {
+	return func(options *Options) {
+		options.MaxWait = maxWait
+	}
+}
+
+// WithStore sets the store of the token buckets, a RedisStore shares the limits between processes
+func WithStore(store Store) Option {
+	return func(options *Options) {
+		options.Store = store
+	}
+}
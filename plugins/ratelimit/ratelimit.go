@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// LimitError is returned when a request would wait longer than allowed for a token
+type LimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e LimitError) Error() string {
+	return "rate limit: " + e.Key + " exceeded, retry after " + e.RetryAfter.String()
+}
+
+// RateLimit returns a handler limiting requests with a token bucket per key
+func RateLimit(opts ...Option) goreq.HandlerFunc {
+	options := newOptions(opts...)
+	return func(ctx *goreq.Context) {
+		key := options.KeyFunc(ctx.Req)
+		limit, ok := options.Limits[key]
+		if !ok {
+			limit = options.Limit
+		}
+		reqCtx := ctx.Req.Context()
+		maxWait := time.Duration(0)
+		if options.Wait {
+			maxWait = time.Duration(math.MaxInt64)
+			if options.MaxWait > 0 {
+				maxWait = options.MaxWait
+			}
+			if deadline, ok := reqCtx.Deadline(); ok && time.Until(deadline) < maxWait {
+				maxWait = time.Until(deadline)
+			}
+		}
+		wait, ok, err := options.Store.Reserve(reqCtx, key, limit, maxWait)
+		if err != nil {
+			ctx.Resp.SetError(err)
+			ctx.Abort()
+			return
+		}
+		if !ok {
+			ctx.Resp.SetError(LimitError{Key: key, RetryAfter: wait})
+			ctx.Abort()
+			return
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-reqCtx.Done():
+				timer.Stop()
+				// the token is given back to the callers still waiting
+				if refunder, ok := options.Store.(Refunder); ok {
+					_ = refunder.Refund(context.WithoutCancel(reqCtx), key, limit)
+				}
+				ctx.Resp.SetError(reqCtx.Err())
+				ctx.Abort()
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
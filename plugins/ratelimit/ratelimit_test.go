@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := goreq.NewClient()
+	c.Use(RateLimit(Rate(20, 2), Wait(false)))
+	for i := 0; i < 2; i++ {
+		if resp := c.Get(ts.URL).Do(); resp.Error() != nil {
+			t.Fatal(resp.Error())
+		}
+	}
+	resp := c.Get(ts.URL).Do()
+	var limitErr LimitError
+	if !errors.As(resp.Error(), &limitErr) || limitErr.RetryAfter <= 0 {
+		t.Fatalf("error = %v, want LimitError", resp.Error())
+	}
+
+	c = goreq.NewClient()
+	c.Use(RateLimit(Rate(20, 1)))
+	begin := time.Now()
+	for i := 0; i < 3; i++ {
+		if resp = c.Get(ts.URL).Do(); resp.Error() != nil {
+			t.Fatal(resp.Error())
+		}
+	}
+	if elapsed := time.Since(begin); elapsed < 90*time.Millisecond {
+		t.Fatalf("requests did not wait, elapsed %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	resp = c.Get(ts.URL).WithContext(ctx).Do()
+	if !errors.As(resp.Error(), &limitErr) {
+		t.Fatalf("error = %v, want LimitError beyond the context deadline", resp.Error())
+	}
+}
+
+// fakeRedis emulates the reserve script with a MemoryStore
+type fakeRedis struct {
+	store *MemoryStore
+	keys  []string
+}
+
+func (f *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	f.keys = append(f.keys, keys...)
+	if script == refundScript {
+		return int64(1), f.store.Refund(ctx, keys[0], Limit{Burst: args[0].(int)})
+	}
+	rate, _ := strconv.ParseFloat(args[0].(string), 64)
+	maxWait := time.Duration(args[2].(int64)) * time.Microsecond
+	wait, ok, err := f.store.Reserve(ctx, keys[0], Limit{Rate: rate, Burst: args[1].(int)}, maxWait)
+	allowed := int64(0)
+	if ok {
+		allowed = 1
+	}
+	return []interface{}{allowed, wait.Microseconds()}, err
+}
+
+func TestRedisStore(t *testing.T) {
+	fake := &fakeRedis{store: NewMemoryStore()}
+	store := NewRedisStore(fake, "goreq:ratelimit:")
+	limit := Limit{Rate: 1, Burst: 1}
+	if _, ok, err := store.Reserve(context.Background(), "api", limit, 0); !ok || err != nil {
+		t.Fatalf("first reserve: ok %v err %v", ok, err)
+	}
+	wait, ok, err := store.Reserve(context.Background(), "api", limit, 0)
+	if ok || err != nil || wait <= 0 || wait > time.Second {
+		t.Fatalf("second reserve: wait %s ok %v err %v", wait, ok, err)
+	}
+	if fake.keys[0] != "goreq:ratelimit:api" {
+		t.Fatalf("keys = %v", fake.keys)
+	}
+	if err = store.Refund(context.Background(), "api", limit); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err = store.Reserve(context.Background(), "api", limit, 0); !ok || err != nil {
+		t.Fatalf("reserve after refund: ok %v err %v", ok, err)
+	}
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	store := NewMemoryStore()
+	c := goreq.NewClient()
+	c.Use(RateLimit(Rate(10, 2), WithStore(store)))
+	for i := 0; i < 2; i++ {
+		if resp := c.Get(ts.URL).Do(); resp.Error() != nil {
+			t.Fatal(resp.Error())
+		}
+	}
+	// the third request waits 100ms for its token, and gives up before
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if resp := c.Get(ts.URL).WithContext(ctx).Do(); !errors.Is(resp.Error(), context.Canceled) {
+		t.Fatalf("error = %v, want the cancellation of the context", resp.Error())
+	}
+
+	// the bucket refills to its burst as if the third request was never sent
+	time.Sleep(250 * time.Millisecond)
+	c = goreq.NewClient()
+	c.Use(RateLimit(Rate(10, 2), WithStore(store), Wait(false)))
+	for i := 0; i < 2; i++ {
+		if resp := c.Get(ts.URL).Do(); resp.Error() != nil {
+			t.Fatalf("request %d: %v", i, resp.Error())
+		}
+	}
+}
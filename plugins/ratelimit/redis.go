@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RedisClient is the part of a Redis client needed by RedisStore, for go-redis:
+//
+//	func (c client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return c.rdb.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// reserveScript implements MemoryStore.Reserve atomically, with the Redis server clock shared by all clients.
+// It returns {ok, wait in microseconds}.
+const reserveScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local max_wait = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) / 1000000 * rate)
+	ts = now
+end
+tokens = tokens - 1
+local wait = 0
+if tokens < 0 then
+	if rate <= 0 then
+		return {0, -1}
+	end
+	wait = math.ceil(-tokens / rate * 1000000)
+end
+if wait > max_wait then
+	return {0, wait}
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(ts))
+local ttl = wait + 1000000
+if rate > 0 then
+	ttl = ttl + math.ceil(burst / rate * 1000000)
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(ttl / 1000))
+return {1, wait}
+`
+
+// refundScript implements MemoryStore.Refund atomically
+const refundScript = `
+local burst = tonumber(ARGV[1])
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens then
+	redis.call("HSET", KEYS[1], "tokens", tostring(math.min(burst, tokens + 1)))
+end
+return 1
+`
+
+var errRedisReply = errors.New("rate limit: unexpected redis reply")
+
+// RedisStore keeps the token buckets in Redis, sharing the limits between processes
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, bool, error) {
+	reply, err := s.client.Eval(ctx, reserveScript, []string{s.prefix + key},
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64), limit.Burst, maxWait.Microseconds())
+	if err != nil {
+		return 0, false, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, false, errRedisReply
+	}
+	allowed, ok1 := values[0].(int64)
+	wait, ok2 := values[1].(int64)
+	if !ok1 || !ok2 {
+		return 0, false, errRedisReply
+	}
+	if wait < 0 {
+		return time.Duration(math.MaxInt64), false, nil
+	}
+	return time.Duration(wait) * time.Microsecond, allowed == 1, nil
+}
+
+// Refund gives back a token reserved from the bucket of key
+func (s *RedisStore) Refund(ctx context.Context, key string, limit Limit) error {
+	_, err := s.client.Eval(ctx, refundScript, []string{s.prefix + key}, limit.Burst)
+	return err
+}
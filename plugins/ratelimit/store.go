@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets
+type Store interface {
+	// Reserve takes a token from the bucket of key if one is available within maxWait,
+	// and returns how long the caller must wait before using it.
+	// If ok is false no token was taken and wait is how long the caller would have to wait.
+	Reserve(ctx context.Context, key string, limit Limit, maxWait time.Duration) (wait time.Duration, ok bool, err error)
+}
+
+// Refunder is implemented by the stores which can give back the token of a request
+// which stopped waiting for it, so that the bucket does not lose it
+type Refunder interface {
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// MemoryStore keeps the token buckets in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key string, limit Limit, maxWait time.Duration) (time.Duration, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+		b.last = now
+	}
+	tokens := b.tokens - 1
+	wait := time.Duration(0)
+	if tokens < 0 {
+		if limit.Rate <= 0 {
+			return time.Duration(math.MaxInt64), false, nil
+		}
+		wait = time.Duration(-tokens / limit.Rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false, nil
+	}
+	b.tokens = tokens
+	return wait, true, nil
+}
+
+// Refund gives back a token reserved from the bucket of key
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package statsd
+
+import (
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// tag formats
+const (
+	TagFormatNone      = ""          // tags are dropped, plain StatsD
+	TagFormatDogStatsD = "dogstatsd" // |#key:value,key:value
+	TagFormatInfluxDB  = "influxdb"  // metric,key=value,key=value
+)
+
+type Options struct {
+	Address       string // udp address of the agent
+	Prefix        string // prefix of every metric name, like "myapp.http."
+	SampleRate    float64
+	TagFormat     string
+	Tags          []string // constant tags added to every metric, as "key:value"
+	MaxPacketSize int      // metrics are batched in packets up to this size, no batching if <= 0
+	FlushInterval time.Duration
+	NameFunc      func(*goreq.Req) string // returns the route tag of a request
+}
+
+type Option func(*Options)
+
+const defaultFlushInterval = 100 * time.Millisecond
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		Address:       "127.0.0.1:8125",
+		Prefix:        "goreq.",
+		SampleRate:    1,
+		TagFormat:     TagFormatDogStatsD,
+		MaxPacketSize: 1432,
+		FlushInterval: defaultFlushInterval,
+		NameFunc: func(r *goreq.Req) string {
+			if r.GetName() == "" {
+				return "other"
+			}
+			return r.GetName()
+		},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.FlushInterval <= 0 {
+		// a partial batch would never be sent
+		options.FlushInterval = defaultFlushInterval
+	}
+	return options
+}
+
+func Address(address string) Option {
+	return func(options *Options) {
+		options.Address = address
+	}
+}
+
+func Prefix(prefix string) Option {
+	return func(options *Options) {
+		options.Prefix = prefix
+	}
+}
+
+// SampleRate sets the ratio of requests reported, the agent scales counters back up
+func SampleRate(rate float64) Option {
+	return func(options *Options) {
+		options.SampleRate = rate
+	}
+}
+
+func TagFormat(format string) Option {
+	return func(options *Options) {
+		options.TagFormat = format
+	}
+}
+
+// Tags adds constant tags, as "key:value"
+func Tags(tags ...string) Option {
+	return func(options *Options) {
+		options.Tags = append(options.Tags, tags...)
+	}
+}
+
+// MaxPacketSize sets the max size of a batch, batching is disabled if <= 0
+func MaxPacketSize(size int) Option {
+	return func(options *Options) {
+		options.MaxPacketSize = size
+	}
+}
+
+// FlushInterval sets how often a partial batch is sent, 100ms if <= 0
+func FlushInterval(interval time.Duration) Option {
+	return func(options *Options) {
+		options.FlushInterval = interval
+	}
+}
+
+// NameFunc sets the function returning the route tag of a request, its values must have a low cardinality
+func NameFunc(nameFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.NameFunc = nameFunc
+	}
+}
@@ -0,0 +1,200 @@
+package statsd
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// metric names, after the prefix
+const (
+	MetricDuration = "request.duration"
+	MetricCount    = "request.count"
+	MetricInFlight = "request.in_flight"
+)
+
+var tagReplacer = strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", "@", "_", "=", "_", " ", "_", "\n", "_")
+
+// Reporter sends request metrics to a StatsD agent over udp
+type Reporter struct {
+	options  Options
+	conn     net.Conn
+	mu       sync.Mutex
+	buf      []byte
+	inFlight map[string]int64
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+func New(opts ...Option) (*Reporter, error) {
+	options := newOptions(opts...)
+	conn, err := net.Dial("udp", options.Address)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reporter{
+		options:  options,
+		conn:     conn,
+		inFlight: make(map[string]int64),
+		done:     make(chan struct{}),
+	}
+	if options.MaxPacketSize > 0 {
+		r.wg.Add(1)
+		go r.flushLoop()
+	}
+	return r, nil
+}
+
+// Handler reports the duration and count of every request, tagged by host, route, method and status class,
+// and the number of requests in flight, tagged by host and route. The host is the one of the request url,
+// like the service name of svc:// urls, so that the series of a request line up.
+func (r *Reporter) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		host := ctx.Req.GetHost()
+		route := r.options.NameFunc(ctx.Req)
+		gaugeTags := []string{"host:" + host, "route:" + route}
+		r.gauge(MetricInFlight, r.addInFlight(gaugeTags, 1), gaugeTags)
+		// deferred, so that a panic of the next handlers does not leave the request in flight
+		defer func() {
+			r.gauge(MetricInFlight, r.addInFlight(gaugeTags, -1), gaugeTags)
+		}()
+		begin := time.Now()
+		ctx.Next()
+		d := time.Since(begin)
+
+		if r.options.SampleRate < 1 && rand.Float64() >= r.options.SampleRate {
+			return
+		}
+		tags := []string{"host:" + host, "route:" + route, "method:" + ctx.Req.GetMethod(), "status_class:" + statusClass(ctx.Resp)}
+		if kind := goreq.ErrorKind(ctx.Resp.Error()); kind != "" {
+			tags = append(tags, "error:"+kind)
+		}
+		r.send(MetricDuration, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64), "ms", tags)
+		r.send(MetricCount, "1", "c", tags)
+	}
+}
+
+// Flush sends the pending batch
+func (r *Reporter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush()
+}
+
+// Close flushes the pending batch and closes the connection
+func (r *Reporter) Close() error {
+	var err error
+	r.once.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+		if err = r.Flush(); err != nil {
+			_ = r.conn.Close()
+			return
+		}
+		err = r.conn.Close()
+	})
+	return err
+}
+
+func (r *Reporter) addInFlight(tags []string, delta int64) int64 {
+	key := strings.Join(tags, ",")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight[key] += delta
+	n := r.inFlight[key]
+	if n == 0 {
+		delete(r.inFlight, key)
+	}
+	return n
+}
+
+func (r *Reporter) gauge(name string, value int64, tags []string) {
+	r.write(r.format(name, strconv.FormatInt(value, 10), "g", 1, tags))
+}
+
+func (r *Reporter) send(name, value, metricType string, tags []string) {
+	r.write(r.format(name, value, metricType, r.options.SampleRate, tags))
+}
+
+// format formats a metric line in the configured tag format
+func (r *Reporter) format(name, value, metricType string, rate float64, tags []string) string {
+	var b strings.Builder
+	b.WriteString(r.options.Prefix)
+	b.WriteString(name)
+	tags = append(append([]string{}, r.options.Tags...), tags...)
+	if r.options.TagFormat == TagFormatInfluxDB {
+		for _, tag := range tags {
+			k, v, _ := strings.Cut(tag, ":")
+			b.WriteString("," + tagReplacer.Replace(k) + "=" + tagReplacer.Replace(v))
+		}
+	}
+	b.WriteString(":" + value + "|" + metricType)
+	if rate < 1 {
+		b.WriteString("|@" + strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	if r.options.TagFormat == TagFormatDogStatsD && len(tags) > 0 {
+		b.WriteString("|#")
+		for i, tag := range tags {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, v, _ := strings.Cut(tag, ":")
+			b.WriteString(tagReplacer.Replace(k) + ":" + tagReplacer.Replace(v))
+		}
+	}
+	return b.String()
+}
+
+func (r *Reporter) write(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.options.MaxPacketSize <= 0 {
+		_, _ = r.conn.Write([]byte(line))
+		return
+	}
+	if len(r.buf) > 0 && len(r.buf)+1+len(line) > r.options.MaxPacketSize {
+		_ = r.flush()
+	}
+	if len(r.buf) > 0 {
+		r.buf = append(r.buf, '\n')
+	}
+	r.buf = append(r.buf, line...)
+}
+
+// flush sends the pending batch, the caller must hold r.mu
+func (r *Reporter) flush() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	_, err := r.conn.Write(r.buf)
+	r.buf = r.buf[:0]
+	return err
+}
+
+func (r *Reporter) flushLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = r.Flush()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func statusClass(resp *goreq.Resp) string {
+	code := resp.StatusCode()
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
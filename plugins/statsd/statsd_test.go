@@ -0,0 +1,159 @@
+package statsd
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func listen(t *testing.T) (*net.UDPConn, func() []string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func() []string {
+		var lines []string
+		buf := make([]byte, 65536)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, err := conn.Read(buf)
+			if err != nil {
+				return lines
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+	}
+	return conn, read
+}
+
+func TestReporter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+	conn, read := listen(t)
+	defer conn.Close()
+
+	reporter, err := New(Address(conn.LocalAddr().String()), Prefix("app."), Tags("env:test"), FlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := goreq.NewClient()
+	c.Use(reporter.Handler())
+	c.Get(ts.URL).WithName("create user").Do()
+	if err = reporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := read()
+	host := strings.TrimPrefix(ts.URL, "http://")
+	host = strings.ReplaceAll(host, ":", "_")
+	want := []string{
+		"app.request.in_flight:1|g|#env:test,host:" + host + ",route:create_user",
+		"app.request.in_flight:0|g|#env:test,host:" + host + ",route:create_user",
+		"app.request.count:1|c|#env:test,host:" + host + ",route:create_user,method:GET,status_class:2xx",
+	}
+	if len(lines) != 4 {
+		t.Fatalf("expected one batch of 4 metrics, got %q", lines)
+	}
+	for _, w := range want {
+		if !contains(lines, w) {
+			t.Errorf("missing %q in %q", w, lines)
+		}
+	}
+	if !strings.HasPrefix(lines[1], "app.request.duration:") || !strings.Contains(lines[1], "|ms|#") {
+		t.Errorf("unexpected timing %q", lines[1])
+	}
+}
+
+func TestReporterService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	conn, read := listen(t)
+	defer conn.Close()
+
+	// a zero interval must not leave the partial batch unsent
+	reporter, err := New(Address(conn.LocalAddr().String()), FlushInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+	c := goreq.NewClient(goreq.WithResolver(goreq.StaticResolver(map[string][]goreq.Endpoint{
+		"orders": {{URL: ts.URL}},
+	})))
+	c.Use(reporter.Handler())
+	if resp := c.Get("svc://orders/items").Do(); resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+
+	lines := read()
+	if len(lines) != 4 {
+		t.Fatalf("expected one batch of 4 metrics, got %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "host:orders,") {
+			t.Errorf("expected every metric to be tagged with the service, got %q", line)
+		}
+	}
+}
+
+func TestReporterPanic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	conn, read := listen(t)
+	defer conn.Close()
+
+	reporter, err := New(Address(conn.LocalAddr().String()), MaxPacketSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+	c := goreq.NewClient()
+	c.Use(reporter.Handler())
+	c.OnAfterResponse(func(*goreq.Resp) error {
+		panic("after response")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the hook to panic")
+			}
+		}()
+		c.Get(ts.URL).Do()
+	}()
+	if len(reporter.inFlight) != 0 {
+		t.Errorf("expected the request to leave the in flight gauge, got %v", reporter.inFlight)
+	}
+	if lines := read(); len(lines) != 2 || !strings.HasPrefix(lines[1], "goreq.request.in_flight:0|g|") {
+		t.Errorf("expected the gauge to be sent back to 0, got %q", lines)
+	}
+}
+
+func TestReporterFormats(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+
+	reporter, err := New(Address(conn.LocalAddr().String()), Prefix(""), TagFormat(TagFormatInfluxDB), MaxPacketSize(0), SampleRate(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Close()
+	reporter.send(MetricCount, "1", "c", []string{"host:a", "route:b"})
+	if got, want := read(), "request.count,host=a,route=b:1|c|@0.5"; len(got) != 1 || got[0] != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func contains(lines []string, s string) bool {
+	for _, l := range lines {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
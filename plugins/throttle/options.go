@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+type Options struct {
+	KeyFunc   func(*goreq.Req) string
+	Reserve   int           // requests kept in reserve, requests pause until the reset once the remaining quota reaches it, 0 if < 0
+	PaceBelow float64       // fraction of the limit below which requests are spread evenly until the reset, disabled if <= 0
+	MaxWait   time.Duration // longest wait before failing with a ThrottledError, no limit if <= 0
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		KeyFunc:   ByHost,
+		PaceBelow: 0.2,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Reserve < 0 {
+		options.Reserve = 0
+	}
+	return options
+}
+
+// ByHost throttles requests per host
+func ByHost(r *goreq.Req) string {
+	return r.GetHost()
+}
+
+func KeyFunc(keyFunc func(*goreq.Req) string) Option {
+	return func(options *Options) {
+		options.KeyFunc = keyFunc
+	}
+}
+
+func Reserve(n int) Option {
+	return func(options *Options) {
+		options.Reserve = n
+	}
+}
+
+func PaceBelow(fraction float64) Option {
+	return func(options *Options) {
+		options.PaceBelow = fraction
+	}
+}
+
+func MaxWait(maxWait time.Duration) Option {
+	return func(options *Options) {
+		options.MaxWait = maxWait
+	}
+}
@@ -0,0 +1,273 @@
+package throttle
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+// ThrottledError is returned when a request would wait longer than allowed for the upstream quota
+type ThrottledError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e ThrottledError) Error() string {
+	return "throttle: " + e.Key + " is throttled, retry after " + e.RetryAfter.String()
+}
+
+// Throttler follows the quota announced by the upstream in its rate limit headers, per key returned by Options.KeyFunc
+type Throttler struct {
+	options Options
+	mu      sync.Mutex
+	keys    map[string]*quota
+}
+
+func New(opts ...Option) *Throttler {
+	return &Throttler{
+		options: newOptions(opts...),
+		keys:    make(map[string]*quota),
+	}
+}
+
+// Throttle returns a handler slowing down or pausing requests according to the rate limit headers of previous responses
+func Throttle(opts ...Option) goreq.HandlerFunc {
+	return New(opts...).Handler()
+}
+
+func (t *Throttler) Handler() goreq.HandlerFunc {
+	return func(ctx *goreq.Context) {
+		key := t.options.KeyFunc(ctx.Req)
+		q := t.quota(key)
+		reqCtx := ctx.Req.Context()
+		maxWait := time.Duration(math.MaxInt64)
+		if t.options.MaxWait > 0 {
+			maxWait = t.options.MaxWait
+		}
+		if deadline, ok := reqCtx.Deadline(); ok && time.Until(deadline) < maxWait {
+			maxWait = time.Until(deadline)
+		}
+		wait, ok := q.reserve(time.Now(), maxWait, &t.options)
+		if !ok {
+			ctx.Resp.SetError(ThrottledError{Key: key, RetryAfter: wait})
+			ctx.Abort()
+			return
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-reqCtx.Done():
+				timer.Stop()
+				ctx.Resp.SetError(reqCtx.Err())
+				ctx.Abort()
+				return
+			}
+		}
+		ctx.Next()
+		if response := ctx.Resp.Response(); response != nil {
+			q.update(response, time.Now())
+		}
+	}
+}
+
+// Wait returns how long the next request of key would wait
+func (t *Throttler) Wait(key string) time.Duration {
+	return t.quota(key).peek(time.Now(), &t.options)
+}
+
+func (t *Throttler) quota(key string) *quota {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q, ok := t.keys[key]
+	if !ok {
+		q = &quota{remaining: -1}
+		t.keys[key] = q
+	}
+	return q
+}
+
+// quota is the last known state of an upstream quota
+type quota struct {
+	mu           sync.Mutex
+	limit        int       // 0 if unknown
+	remaining    int       // -1 if unknown
+	reset        time.Time // when the quota is restored
+	blockedUntil time.Time // set by a Retry-After
+	next         time.Time // earliest start of the next paced request
+}
+
+// reserve returns how long a request has to wait and takes it from the remaining quota,
+// nothing is taken if the wait exceeds maxWait
+func (q *quota) reserve(now time.Time, maxWait time.Duration, options *Options) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	start, paced := q.start(now, options)
+	wait := start.Sub(now)
+	if wait > maxWait {
+		return wait, false
+	}
+	if paced && q.remaining > 0 {
+		q.next = start.Add(q.reset.Sub(start) / time.Duration(q.remaining))
+	}
+	if q.remaining > 0 {
+		q.remaining--
+	}
+	return wait, true
+}
+
+func (q *quota) peek(now time.Time, options *Options) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	start, _ := q.start(now, options)
+	return start.Sub(now)
+}
+
+// start returns when the next request may start and whether it is paced, the caller must hold q.mu
+func (q *quota) start(now time.Time, options *Options) (time.Time, bool) {
+	if now.Before(q.blockedUntil) {
+		return q.blockedUntil, false
+	}
+	if q.remaining < 0 || !now.Before(q.reset) {
+		// the quota is unknown or restored, until the next response tells otherwise
+		q.remaining = -1
+		return now, false
+	}
+	if q.remaining <= options.Reserve {
+		return q.reset, false
+	}
+	if options.PaceBelow > 0 && q.limit > 0 && float64(q.remaining) < options.PaceBelow*float64(q.limit) {
+		if q.next.After(now) {
+			return q.next, true
+		}
+		return now, true
+	}
+	return now, false
+}
+
+func (q *quota) update(response *http.Response, now time.Time) {
+	info := parseHeaders(response.Header, now)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if info.limit > 0 {
+		q.limit = info.limit
+	}
+	if info.remaining >= 0 && !info.reset.IsZero() {
+		q.remaining = info.remaining
+		q.reset = info.reset
+	}
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+	// the whole key backs off, and not only the rejected request
+	until := info.retryAfter
+	if until.IsZero() && response.StatusCode == http.StatusTooManyRequests {
+		until = info.reset
+	}
+	if until.After(q.blockedUntil) {
+		q.blockedUntil = until
+	}
+}
+
+// rateLimit is the quota found in the headers of a response
+type rateLimit struct {
+	limit      int // 0 if absent
+	remaining  int // -1 if absent
+	reset      time.Time
+	retryAfter time.Time
+}
+
+// parseHeaders reads the IETF RateLimit header fields, in their structured and separated forms,
+// the common X-RateLimit-* headers and Retry-After
+func parseHeaders(h http.Header, now time.Time) rateLimit {
+	info := rateLimit{remaining: -1}
+	if v := h.Get("RateLimit"); v != "" {
+		parseStructured(v, now, &info)
+	}
+	if v := h.Get("RateLimit-Policy"); v != "" && info.limit == 0 {
+		policy := rateLimit{remaining: -1}
+		parseStructured(v, now, &policy)
+		info.limit = policy.limit
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-", "X-Rate-Limit-"} {
+		if info.limit == 0 {
+			if n, ok := leadingInt(h.Get(prefix + "Limit")); ok {
+				info.limit = n
+			}
+		}
+		if info.remaining < 0 {
+			if n, ok := leadingInt(h.Get(prefix + "Remaining")); ok {
+				info.remaining = n
+			}
+		}
+		if info.reset.IsZero() {
+			info.reset = parseReset(h.Get(prefix+"Reset"), now)
+		}
+	}
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			info.retryAfter = now.Add(time.Duration(seconds) * time.Second)
+		} else if t, err := http.ParseTime(v); err == nil {
+			info.retryAfter = t
+		}
+	}
+	return info
+}
+
+// parseStructured parses `limit=100, remaining=50, reset=30` and `"default";r=50;t=30;q=100`
+func parseStructured(v string, now time.Time, info *rateLimit) {
+	for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+		k, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(k) {
+		case "limit", "q":
+			info.limit = n
+		case "remaining", "r":
+			info.remaining = n
+		case "reset", "t":
+			info.reset = now.Add(time.Duration(n) * time.Second)
+		}
+	}
+}
+
+// parseReset parses a reset given in seconds from now, or as a unix timestamp in seconds or milliseconds
+func parseReset(v string, now time.Time) time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		if t, err := http.ParseTime(v); err == nil {
+			return t
+		}
+		return time.Time{}
+	}
+	switch {
+	case f >= 1e12:
+		return time.UnixMilli(int64(f))
+	case f >= 1e9:
+		return time.Unix(0, int64(f*float64(time.Second)))
+	}
+	return now.Add(time.Duration(f * float64(time.Second)))
+}
+
+// leadingInt parses the number before any policy, like in `100, 100;w=60`
+func leadingInt(v string) (int, bool) {
+	if i := strings.IndexAny(v, ",;"); i >= 0 {
+		v = v[:i]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	return n, err == nil
+}
@@ -0,0 +1,103 @@
+package throttle
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aiscrm/goreq"
+)
+
+func TestParseHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		header    http.Header
+		limit     int
+		remaining int
+		reset     time.Time
+	}{
+		{http.Header{"X-Ratelimit-Limit": {"60"}, "X-Ratelimit-Remaining": {"5"}, "X-Ratelimit-Reset": {"1700000030"}}, 60, 5, now.Add(30 * time.Second)},
+		{http.Header{"X-Ratelimit-Remaining": {"5"}, "X-Ratelimit-Reset": {"1.5"}}, 0, 5, now.Add(1500 * time.Millisecond)},
+		{http.Header{"Ratelimit-Limit": {"100, 100;w=60"}, "Ratelimit-Remaining": {"50"}, "Ratelimit-Reset": {"10"}}, 100, 50, now.Add(10 * time.Second)},
+		{http.Header{"Ratelimit": {"limit=100, remaining=50, reset=10"}}, 100, 50, now.Add(10 * time.Second)},
+		{http.Header{"Ratelimit": {`"default";r=50;t=10`}, "Ratelimit-Policy": {`"default";q=100;w=60`}}, 100, 50, now.Add(10 * time.Second)},
+	}
+	for i, test := range tests {
+		info := parseHeaders(test.header, now)
+		if info.limit != test.limit || info.remaining != test.remaining || !info.reset.Equal(test.reset) {
+			t.Errorf("%d: got %+v", i, info)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/limited":
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/exhausted":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "60")
+		}
+	}))
+	defer ts.Close()
+
+	throttler := New(KeyFunc(func(r *goreq.Req) string { return r.GetPath() }), MaxWait(10*time.Millisecond))
+	c := goreq.NewClient()
+	c.Use(throttler.Handler())
+
+	for _, path := range []string{"/limited", "/exhausted"} {
+		if resp := c.Get(ts.URL + path).Do(); resp.Error() != nil {
+			t.Fatal(resp.Error())
+		}
+		resp := c.Get(ts.URL + path).Do()
+		var throttled ThrottledError
+		if !errors.As(resp.Error(), &throttled) || throttled.Key != path || throttled.RetryAfter < 59*time.Second {
+			t.Fatalf("%s: expected a ThrottledError, got %v", path, resp.Error())
+		}
+		if wait := throttler.Wait(path); wait < 59*time.Second {
+			t.Errorf("%s: unexpected wait %s", path, wait)
+		}
+	}
+	if resp := c.Get(ts.URL + "/other").Do(); resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests to reach the server, got %d", requests)
+	}
+}
+
+func TestPace(t *testing.T) {
+	now := time.Now()
+	options := newOptions(PaceBelow(0.5))
+	q := &quota{limit: 100, remaining: 10, reset: now.Add(time.Second)}
+	first, _ := q.reserve(now, time.Hour, &options)
+	second, _ := q.reserve(now, time.Hour, &options)
+	if first != 0 || second != 100*time.Millisecond {
+		t.Fatalf("expected requests spread over the reset, got %s and %s", first, second)
+	}
+}
+
+func TestPaceNegativeReserve(t *testing.T) {
+	now := time.Now()
+	options := newOptions(Reserve(-1))
+	q := &quota{limit: 100, remaining: 1, reset: now.Add(time.Second)}
+	if wait, ok := q.reserve(now, time.Hour, &options); wait != 0 || !ok {
+		t.Fatalf("expected the last request of the quota to start, got %s", wait)
+	}
+	// the quota is exhausted, the next request waits for the reset
+	if wait, ok := q.reserve(now, time.Hour, &options); wait != time.Second || !ok {
+		t.Fatalf("expected the request to wait for the reset, got %s", wait)
+	}
+
+	options.Reserve = -1
+	q = &quota{limit: 100, remaining: 0, reset: now.Add(time.Second)}
+	if _, ok := q.reserve(now, time.Hour, &options); !ok {
+		t.Fatal("expected the request to be paced")
+	}
+}
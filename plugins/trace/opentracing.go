@@ -1,12 +1,15 @@
 package trace
 
 import (
+	"net/http"
 	"net/http/httputil"
+	"strconv"
 	"strings"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/aiscrm/goreq"
@@ -15,6 +18,20 @@ import (
 const spanPrefix = "req"
 const spanDelimiter = ":"
 
+// attributes of the OpenTelemetry HTTP client semantic conventions
+const (
+	attrHTTPRequestMethod      = attribute.Key("http.request.method")
+	attrHTTPRequestBodySize    = attribute.Key("http.request.body.size")
+	attrHTTPResponseStatusCode = attribute.Key("http.response.status_code")
+	attrHTTPResponseBodySize   = attribute.Key("http.response.body.size")
+	attrURLFull                = attribute.Key("url.full")
+	attrServerAddress          = attribute.Key("server.address")
+	attrServerPort             = attribute.Key("server.port")
+	attrNetworkProtocolVersion = attribute.Key("network.protocol.version")
+	attrErrorType              = attribute.Key("error.type")
+	attrDumpBody               = attribute.Key("body")
+)
+
 func Trace(opts ...Option) goreq.HandlerFunc {
 	options := Options{}
 	for _, o := range opts {
@@ -26,23 +43,75 @@ func Trace(opts ...Option) goreq.HandlerFunc {
 		if name == "" {
 			name = ctx.Req.GetHost() + spanDelimiter + ctx.Req.GetPath()
 		}
-		_, span := tracer.Start(ctx.Req.Context(), strings.Join([]string{spanPrefix, name}, spanDelimiter))
+		parent := ctx.Req.Context()
+		spanCtx, span := tracer.Start(parent, strings.Join([]string{spanPrefix, name}, spanDelimiter),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrHTTPRequestMethod.String(ctx.Req.GetMethod())),
+		)
 		defer span.End()
+		// propagate the span to the handlers below and to the downstream service
+		otel.GetTextMapPropagator().Inject(spanCtx, propagation.HeaderCarrier(ctx.Req.GetHeader()))
+		ctx.Req.WithContext(spanCtx)
 		ctx.Next()
-		span.SetAttributes(attribute.Key("http.method").String(ctx.Req.GetMethod()))
-		span.SetAttributes(attribute.Key("http.status_code").Int(ctx.Resp.StatusCode()))
-		span.SetAttributes(attribute.Key("http.url").String(ctx.Resp.Request().URL.RequestURI()))
-		if ctx.Resp.Error() != nil {
-			span.RecordError(ctx.Resp.Error())
-			span.SetStatus(codes.Error, ctx.Resp.Error().Error())
+		ctx.Req.WithContext(parent)
+
+		if request := ctx.Resp.Request(); request != nil && request.URL != nil {
+			span.SetAttributes(requestAttributes(request)...)
+		}
+		span.SetAttributes(attrHTTPRequestBodySize.Int(len(ctx.Req.GetBody())))
+		if response := ctx.Resp.Response(); response != nil {
+			span.SetAttributes(attrHTTPResponseStatusCode.Int(response.StatusCode))
+			if response.ContentLength >= 0 {
+				span.SetAttributes(attrHTTPResponseBodySize.Int64(response.ContentLength))
+			}
+			if response.ProtoMajor > 0 {
+				span.SetAttributes(attrNetworkProtocolVersion.String(protocolVersion(response)))
+			}
 		}
-		if options.DumpRequest {
-			reqData, _ := httputil.DumpRequest(ctx.Resp.Request(), true)
-			span.AddEvent("dump.request", trace.WithAttributes(attribute.Key("body").String(string(reqData))))
+		if err := ctx.Resp.Error(); err != nil {
+			span.SetAttributes(attrErrorType.String(goreq.ErrorKind(err)))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if code := ctx.Resp.StatusCode(); code >= http.StatusBadRequest {
+			// client spans are failed for 4xx and 5xx, as per the semantic conventions
+			span.SetAttributes(attrErrorType.String(strconv.Itoa(code)))
+			span.SetStatus(codes.Error, http.StatusText(code))
 		}
-		if options.DumpResponse {
+		if options.DumpRequest && ctx.Resp.Request() != nil {
+			reqData, _ := httputil.DumpRequest(ctx.Resp.Request(), false)
+			span.AddEvent("dump.request", trace.WithAttributes(attrDumpBody.String(string(reqData)+string(ctx.Req.GetBody()))))
+		}
+		if options.DumpResponse && ctx.Resp.Response() != nil {
 			respData, _ := httputil.DumpResponse(ctx.Resp.Response(), true)
-			span.AddEvent("dump.response", trace.WithAttributes(attribute.Key("body").String(string(respData))))
+			span.AddEvent("dump.response", trace.WithAttributes(attrDumpBody.String(string(respData))))
 		}
 	}
 }
+
+func requestAttributes(request *http.Request) []attribute.KeyValue {
+	u := *request.URL
+	u.User = nil // credentials must not be recorded
+	attrs := []attribute.KeyValue{
+		attrURLFull.String(u.String()),
+		attrServerAddress.String(u.Hostname()),
+	}
+	port := u.Port()
+	switch {
+	case port != "":
+	case u.Scheme == "https":
+		port = "443"
+	case u.Scheme == "http":
+		port = "80"
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attrServerPort.Int(p))
+	}
+	return attrs
+}
+
+func protocolVersion(response *http.Response) string {
+	if response.ProtoMajor > 1 {
+		return strconv.Itoa(response.ProtoMajor)
+	}
+	return strconv.Itoa(response.ProtoMajor) + "." + strconv.Itoa(response.ProtoMinor)
+}
@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aiscrm/goreq"
+)
+
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTrace(t *testing.T) {
+	recorder := newRecorder(t)
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("not found"))
+	}))
+	defer ts.Close()
+
+	parentCtx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	c := goreq.NewClient()
+	c.Use(Trace(ServiceName("test")))
+	resp := c.Post(ts.URL + "/users/1").WithName("get-user").WithContext(parentCtx).WithBody("{}").Do()
+	parent.End()
+	if resp.Error() != nil {
+		t.Fatal(resp.Error())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the client and the parent spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "req:get-user" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected span %q of kind %v", span.Name(), span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected the span to be a child of the request context")
+	}
+	attrs := attributes(span)
+	expected := map[attribute.Key]attribute.Value{
+		attrHTTPRequestMethod:      attribute.StringValue(http.MethodPost),
+		attrHTTPRequestBodySize:    attribute.IntValue(2),
+		attrHTTPResponseStatusCode: attribute.IntValue(http.StatusNotFound),
+		attrHTTPResponseBodySize:   attribute.Int64Value(9),
+		attrURLFull:                attribute.StringValue(ts.URL + "/users/1"),
+		attrServerAddress:          attribute.StringValue("127.0.0.1"),
+		attrNetworkProtocolVersion: attribute.StringValue("1.1"),
+		attrErrorType:              attribute.StringValue("404"),
+	}
+	for key, value := range expected {
+		if attrs[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value.Emit(), attrs[key].Emit())
+		}
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected a 404 to fail the span, got %v", span.Status())
+	}
+
+	// the downstream service continues the trace of the client span
+	carrier := propagation.HeaderCarrier{"Traceparent": []string{traceparent}}
+	remote := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+	if remote.TraceID() != span.SpanContext().TraceID() || remote.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("unexpected traceparent %q", traceparent)
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	recorder := newRecorder(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	c := goreq.NewClient()
+	c.Use(Trace())
+	if resp := c.Get(ts.URL).Do(); resp.Error() == nil {
+		t.Fatal("expected the request to fail")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if kind := attributes(span)[attrErrorType].AsString(); kind != goreq.ErrorKindConnection {
+		t.Errorf("expected a connection error, got %q", kind)
+	}
+	if span.Status().Code != codes.Error || len(span.Events()) != 1 || span.Events()[0].Name != "exception" {
+		t.Errorf("expected the error to be recorded, got %v %v", span.Status(), span.Events())
+	}
+}
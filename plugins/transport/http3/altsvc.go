@@ -0,0 +1,48 @@
+package http3
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxAge of an alternative service, as per RFC 7838
+const defaultMaxAge = 24 * time.Hour
+
+// altSvc is the HTTP/3 alternative of an origin
+type altSvc struct {
+	addr    string // host:port, the host is empty for the host of the origin
+	expires time.Time
+}
+
+// parseAltSvc returns the first h3 alternative of an Alt-Svc header like `h3=":443"; ma=86400, h2=":443"`,
+// and whether the header clears the alternatives of the origin
+func parseAltSvc(header string, now time.Time) (alt altSvc, found, clear bool) {
+	if strings.TrimSpace(header) == "clear" {
+		return altSvc{}, false, true
+	}
+	for _, value := range strings.Split(header, ",") {
+		params := strings.Split(value, ";")
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok || protocol != "h3" {
+			continue
+		}
+		authority = strings.Trim(authority, `"`)
+		if _, _, err := net.SplitHostPort(authority); err != nil {
+			continue
+		}
+		maxAge := defaultMaxAge
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if k != "ma" {
+				continue
+			}
+			if seconds, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+		return altSvc{addr: authority, expires: now.Add(maxAge)}, true, false
+	}
+	return altSvc{}, false, false
+}
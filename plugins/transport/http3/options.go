@@ -0,0 +1,69 @@
+package http3
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+type Options struct {
+	TLSClientConfig *tls.Config
+	QUICConfig      *quic.Config
+	Fallback        http.RoundTripper // transport of the requests not sent over HTTP/3, HTTP/1.1 and HTTP/2 by default
+	PriorKnowledge  bool              // try HTTP/3 first for every https request, without waiting for an Alt-Svc
+	BrokenTimeout   time.Duration     // how long an origin which failed over QUIC is sent over the fallback only
+}
+
+type Option func(*Options)
+
+func newOptions(opts ...Option) Options {
+	options := Options{
+		BrokenTimeout: 5 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Fallback == nil {
+		fallback := http.DefaultTransport.(*http.Transport).Clone()
+		if options.TLSClientConfig != nil {
+			fallback.TLSClientConfig = options.TLSClientConfig.Clone()
+		}
+		fallback.ForceAttemptHTTP2 = true
+		options.Fallback = fallback
+	}
+	return options
+}
+
+// TLSClientConfig sets the tls config of both the QUIC connections and the fallback transport by default
+func TLSClientConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.TLSClientConfig = config
+	}
+}
+
+func QUICConfig(config *quic.Config) Option {
+	return func(options *Options) {
+		options.QUICConfig = config
+	}
+}
+
+// Fallback sets the transport used when HTTP/3 is not advertised or fails
+func Fallback(transport http.RoundTripper) Option {
+	return func(options *Options) {
+		options.Fallback = transport
+	}
+}
+
+func PriorKnowledge(enable bool) Option {
+	return func(options *Options) {
+		options.PriorKnowledge = enable
+	}
+}
+
+func BrokenTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.BrokenTimeout = timeout
+	}
+}
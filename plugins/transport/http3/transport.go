@@ -0,0 +1,190 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	quichttp3 "github.com/quic-go/quic-go/http3"
+)
+
+// Transport sends https requests over HTTP/3 to the origins advertising it in Alt-Svc, or to all of them
+// with PriorKnowledge, and falls back to HTTP/1.1 or HTTP/2 when QUIC fails.
+// Requests which may have reached the server over QUIC are only sent again over the fallback when idempotent.
+// It is a http.RoundTripper for goreq.WithTransport:
+//
+//	client := goreq.NewClient(goreq.WithTransport(http3.New()))
+type Transport struct {
+	options Options
+	h3      *quichttp3.Transport
+	mu      sync.Mutex
+	altSvc  map[string]altSvc    // alternatives per origin authority
+	broken  map[string]time.Time // origins QUIC failed for, until when
+}
+
+func New(opts ...Option) *Transport {
+	t := &Transport{
+		options: newOptions(opts...),
+		altSvc:  make(map[string]altSvc),
+		broken:  make(map[string]time.Time),
+	}
+	t.h3 = &quichttp3.Transport{
+		TLSClientConfig: t.options.TLSClientConfig,
+		QUICConfig:      t.options.QUICConfig,
+		Dial:            t.dial,
+	}
+	return t
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin := authority(req)
+	if req.URL.Scheme != "https" || !t.useHTTP3(origin, time.Now()) {
+		resp, err := t.options.Fallback.RoundTrip(req)
+		if err == nil {
+			t.learn(origin, resp)
+		}
+		return resp, err
+	}
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil {
+		t.learn(origin, resp)
+		return resp, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+	t.markBroken(origin, time.Now())
+	// a request which may have reached the server is only sent again when it is idempotent
+	var dialErr dialError
+	if !errors.As(err, &dialErr) && !isIdempotent(req) {
+		return nil, err
+	}
+	// the body may have been read, the request can only be sent again when it can be rewound
+	fallback := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		if fallback.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.options.Fallback.RoundTrip(fallback)
+}
+
+// Close closes the QUIC connections and the idle connections of the fallback
+func (t *Transport) Close() error {
+	if closer, ok := t.options.Fallback.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	return t.h3.Close()
+}
+
+func (t *Transport) useHTTP3(origin string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until, ok := t.broken[origin]; ok {
+		if now.Before(until) {
+			return false
+		}
+		delete(t.broken, origin)
+	}
+	if t.options.PriorKnowledge {
+		return true
+	}
+	alt, ok := t.altSvc[origin]
+	if ok && !now.Before(alt.expires) {
+		delete(t.altSvc, origin)
+		return false
+	}
+	return ok
+}
+
+// learn records the HTTP/3 alternative advertised by a response
+func (t *Transport) learn(origin string, resp *http.Response) {
+	header := resp.Header.Get("Alt-Svc")
+	if header == "" {
+		return
+	}
+	alt, found, clear := parseAltSvc(header, time.Now())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case clear:
+		delete(t.altSvc, origin)
+	case found:
+		t.altSvc[origin] = alt
+	}
+}
+
+func (t *Transport) markBroken(origin string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.broken[origin] = now.Add(t.options.BrokenTimeout)
+}
+
+// dial connects to the alternative of the origin addr, or to the origin itself
+func (t *Transport) dial(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	t.mu.Lock()
+	alt, ok := t.altSvc[addr]
+	t.mu.Unlock()
+	if ok {
+		host, port, err := net.SplitHostPort(alt.addr)
+		if err != nil {
+			return nil, err
+		}
+		if host == "" {
+			if host, _, err = net.SplitHostPort(addr); err != nil {
+				return nil, err
+			}
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	// no 0-RTT, so that nothing is sent before the handshake completed and a failed dial can always fall back
+	conn, err := quic.DialAddr(ctx, addr, tlsCfg, cfg)
+	if err != nil {
+		return nil, dialError{err}
+	}
+	return conn, nil
+}
+
+// dialError is a failure to connect, before any request was sent on the connection
+type dialError struct {
+	err error
+}
+
+func (e dialError) Error() string {
+	return e.err.Error()
+}
+
+func (e dialError) Unwrap() error {
+	return e.err
+}
+
+// isIdempotent returns whether req can be sent again, like net/http does for its retries
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
+
+// authority returns the host:port of the origin of req
+func authority(req *http.Request) string {
+	host, port := req.URL.Hostname(), req.URL.Port()
+	if port == "" {
+		port = "443"
+		if req.URL.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,138 @@
+package http3
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	quichttp3 "github.com/quic-go/quic-go/http3"
+)
+
+var resets int32
+
+// newServers starts a tls server advertising altSvc, and a HTTP/3 server with the same certificate
+func newServers(t *testing.T, altSvc func(udpPort int) string) (*httptest.Server, *Transport) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpPort := conn.LocalAddr().(*net.UDPAddr).Port
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc(udpPort))
+		if streamer, ok := w.(quichttp3.HTTPStreamer); ok && r.Header.Get("X-Reset") != "" {
+			// the request reached the server, which fails before answering
+			atomic.AddInt32(&resets, 1)
+			streamer.HTTPStream().CancelWrite(quic.StreamErrorCode(quichttp3.ErrCodeInternalError))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(append([]byte(r.Proto+" "), body...))
+	})
+	ts := httptest.NewUnstartedServer(handler)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	h3 := &quichttp3.Server{Handler: handler, TLSConfig: quichttp3.ConfigureTLSConfig(ts.TLS)}
+	go func() { _ = h3.Serve(conn) }()
+	t.Cleanup(func() { _ = h3.Close() })
+
+	transport := New(
+		TLSClientConfig(ts.Client().Transport.(*http.Transport).TLSClientConfig),
+		QUICConfig(&quic.Config{HandshakeIdleTimeout: 500 * time.Millisecond}),
+	)
+	t.Cleanup(func() { _ = transport.Close() })
+	return ts, transport
+}
+
+func post(t *testing.T, transport *Transport, url, body string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return string(data)
+}
+
+func TestAltSvc(t *testing.T) {
+	ts, transport := newServers(t, func(udpPort int) string {
+		return fmt.Sprintf(`h3=":%d"; ma=60`, udpPort)
+	})
+	if got := post(t, transport, ts.URL, "a"); got != "HTTP/2.0 a" {
+		t.Fatalf("expected the first request over the fallback, got %q", got)
+	}
+	if got := post(t, transport, ts.URL, "b"); got != "HTTP/3.0 b" {
+		t.Fatalf("expected HTTP/3 once advertised, got %q", got)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	// nothing listens on the advertised port
+	dead, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	deadPort := dead.LocalAddr().(*net.UDPAddr).Port
+	_ = dead.Close()
+	ts, transport := newServers(t, func(int) string {
+		return fmt.Sprintf(`h3=":%d"`, deadPort)
+	})
+	post(t, transport, ts.URL, "")
+	if got := post(t, transport, ts.URL, "retried"); got != "HTTP/2.0 retried" {
+		t.Fatalf("expected the request to fall back, got %q", got)
+	}
+	if transport.useHTTP3(ts.Listener.Addr().String(), time.Now()) {
+		t.Error("expected the origin to be marked broken")
+	}
+}
+
+func TestNoReplay(t *testing.T) {
+	ts, transport := newServers(t, func(udpPort int) string {
+		return fmt.Sprintf(`h3=":%d"; ma=60`, udpPort)
+	})
+	client := &http.Client{Transport: transport}
+	post(t, transport, ts.URL, "")
+
+	atomic.StoreInt32(&resets, 0)
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("once"))
+	req.Header.Set("X-Reset", "1")
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the POST not to be sent again over the fallback, got %s", resp.Proto)
+	}
+	if n := atomic.LoadInt32(&resets); n != 1 {
+		t.Fatalf("expected the POST to be sent once, got %d", n)
+	}
+
+	// idempotent requests fall back
+	transport.mu.Lock()
+	delete(transport.broken, ts.Listener.Addr().String())
+	transport.mu.Unlock()
+	req, _ = http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("X-Reset", "1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("expected the GET to fall back, got %s", resp.Proto)
+	}
+}
+
+func TestParseAltSvc(t *testing.T) {
+	now := time.Now()
+	alt, found, _ := parseAltSvc(`h2=":443", h3="cdn.example:8443"; ma=3600; persist=1`, now)
+	if !found || alt.addr != "cdn.example:8443" || !alt.expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected alternative %+v", alt)
+	}
+	if _, _, clear := parseAltSvc("clear", now); !clear {
+		t.Error("expected clear")
+	}
+}
@@ -0,0 +1,217 @@
+package goreq
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HostPolicy overrides the connection pool settings of a host, zero values keep the client's settings
+type HostPolicy struct {
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	ResponseHeaderTimeout time.Duration
+}
+
+// HostStats counts the connections dialed to an address
+type HostStats struct {
+	Open  int
+	Idle  int
+	InUse int // connections with a request in flight or a response body not closed yet
+}
+
+// hostTransport sends the requests of the hosts with a policy through their own transport
+type hostTransport struct {
+	base       *http.Transport
+	policies   map[string]HostPolicy
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func newHostTransport(base *http.Transport, policies map[string]HostPolicy) *hostTransport {
+	return &hostTransport{
+		base:       base,
+		policies:   policies,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport(req.URL).RoundTrip(req)
+}
+
+// transport returns the transport of the policy of host:port, or host, or the base transport
+func (t *hostTransport) transport(u *url.URL) *http.Transport {
+	host := u.Hostname()
+	if _, ok := t.policies[u.Host]; ok {
+		host = u.Host
+	}
+	policy, ok := t.policies[host]
+	if !ok {
+		return t.base
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if transport, ok := t.transports[host]; ok {
+		return transport
+	}
+	transport := t.base.Clone()
+	if policy.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = policy.MaxIdleConnsPerHost
+	}
+	if policy.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = policy.MaxConnsPerHost
+	}
+	if policy.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = policy.IdleConnTimeout
+	}
+	if policy.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = policy.ResponseHeaderTimeout
+	}
+	t.transports[host] = transport
+	return transport
+}
+
+func (t *hostTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// connTracker tracks the connections dialed by the client's transport, per dialed address
+type connTracker struct {
+	idleReadTimeout time.Duration
+	mu              sync.Mutex
+	conns           map[*trackedConn]struct{}
+}
+
+func newConnTracker(idleReadTimeout time.Duration) *connTracker {
+	return &connTracker{
+		idleReadTimeout: idleReadTimeout,
+		conns:           make(map[*trackedConn]struct{}),
+	}
+}
+
+func (t *connTracker) dialContext(dial DialContext) DialContext {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := &trackedConn{Conn: conn, tracker: t, addr: addr}
+		t.mu.Lock()
+		t.conns[tc] = struct{}{}
+		t.mu.Unlock()
+		return tc, nil
+	}
+}
+
+// trace marks the connection of request in use until its response body is closed
+func (t *connTracker) trace(request *http.Request) (*http.Request, func(*http.Response, error)) {
+	var conn *trackedConn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			// a retried request gets another connection
+			if conn != nil {
+				atomic.AddInt32(&conn.active, -1)
+			}
+			if conn = unwrapConn(info.Conn); conn != nil {
+				atomic.AddInt32(&conn.active, 1)
+			}
+		},
+	}
+	done := func(response *http.Response, err error) {
+		if conn == nil {
+			return
+		}
+		if err != nil || response.Body == nil {
+			atomic.AddInt32(&conn.active, -1)
+			return
+		}
+		response.Body = &releaseBody{ReadCloser: response.Body, conn: conn}
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace)), done
+}
+
+func (t *connTracker) stats() map[string]HostStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hosts := make(map[string]HostStats)
+	for conn := range t.conns {
+		stats := hosts[conn.addr]
+		stats.Open++
+		if atomic.LoadInt32(&conn.active) > 0 {
+			stats.InUse++
+		} else {
+			stats.Idle++
+		}
+		hosts[conn.addr] = stats
+	}
+	return hosts
+}
+
+// unwrapConn returns the tracked connection under conn, like the one of a tls.Conn
+func unwrapConn(conn net.Conn) *trackedConn {
+	for {
+		switch c := conn.(type) {
+		case *trackedConn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+	addr    string
+	active  int32
+	once    sync.Once
+}
+
+// Read fails once no data arrived for the idle read timeout
+func (c *trackedConn) Read(b []byte) (int, error) {
+	if c.tracker.idleReadTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.tracker.idleReadTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.conns, c)
+		c.tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// releaseBody releases its connection when it is closed
+type releaseBody struct {
+	io.ReadCloser
+	conn *trackedConn
+	once sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		atomic.AddInt32(&b.conn.active, -1)
+	})
+	return err
+}
@@ -0,0 +1,58 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPoolStats(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer ts.Close()
+	addr := ts.Listener.Addr().String()
+
+	c := NewClient(WithIdleReadTimeout(200 * time.Millisecond))
+	done := make(chan *Resp)
+	go func() { done <- c.Get(ts.URL + "/slow").Do() }()
+	waitHostStats(t, c, addr, HostStats{Open: 1, InUse: 1})
+	close(release)
+	(<-done).Consume()
+	waitHostStats(t, c, addr, HostStats{Open: 1, Idle: 1})
+	c.Get(ts.URL).Do().Consume()
+	waitHostStats(t, c, addr, HostStats{Open: 1, Idle: 1})
+	// the idle connection is closed by the idle read timeout
+	waitHostStats(t, c, addr, HostStats{})
+}
+
+func waitHostStats(t *testing.T, c Client, addr string, want HostStats) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Hosts[addr] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want %+v", c.Stats().Hosts[addr], want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHostPolicy(t *testing.T) {
+	c := NewClient(WithMaxConnsPerHost(8), WithHostPolicy("api.example:8443", HostPolicy{MaxConnsPerHost: 2}))
+	transport := c.(*client).httpClient.Transport.(*hostTransport)
+	for rawURL, want := range map[string]int{
+		"https://api.example:8443/a": 2,
+		"https://api.example/a":      8,
+		"https://other.example/a":    8,
+	} {
+		u, _ := url.Parse(rawURL)
+		if got := transport.transport(u).MaxConnsPerHost; got != want {
+			t.Errorf("%s: got MaxConnsPerHost %d, want %d", rawURL, got, want)
+		}
+	}
+}
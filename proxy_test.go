@@ -0,0 +1,121 @@
+package goreq
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	var auth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Proxy-Authorization")
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+	proxyURL := "http://user:secret@" + proxy.Listener.Addr().String()
+
+	c := NewClient(WithProxyURL(proxyURL))
+	if body := c.Get("http://upstream.invalid/a").Do().String(); body != "proxied http://upstream.invalid/a" {
+		t.Fatalf("expected the request to go through the proxy, got %q", body)
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret")); auth != want {
+		t.Errorf("got Proxy-Authorization %q, want %q", auth, want)
+	}
+
+	direct := NewClient(WithProxy(func(*http.Request) (*url.URL, error) { return nil, nil }))
+	if body := direct.Get("http://upstream.invalid/b").WithProxy(proxyURL).Do().String(); body != "proxied http://upstream.invalid/b" {
+		t.Fatalf("expected the request proxy to take precedence, got %q", body)
+	}
+}
+
+func TestProxyPool(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	dead, _ := net.Listen("tcp", "127.0.0.1:0")
+	deadURL := "http://" + dead.Addr().String()
+	_ = dead.Close()
+
+	pool, err := NewProxyPool([]string{deadURL, proxy.URL}, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(WithProxyPool(pool))
+	for i := 0; i < 4; i++ {
+		c.Get("http://upstream.invalid/").Do()
+	}
+	stats := pool.Proxies()
+	if !stats[0].Down || stats[0].Requests != 1 || stats[1].Down || stats[1].Requests != 3 {
+		t.Fatalf("expected the dead proxy to be put aside, got %+v", stats)
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	handshakes := make(chan [2]string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serveSOCKS5(conn, handshakes)
+	}()
+
+	proxyURL := "socks5h://user:secret@" + l.Addr().String()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+	if body := NewClient().Get("http://localhost:" + port).WithProxy(proxyURL).Do().String(); body != "hello" {
+		t.Fatalf("unexpected response %q", body)
+	}
+	if handshake := <-handshakes; handshake != [2]string{"user:secret", "localhost:" + port} {
+		t.Errorf("unexpected handshake, user and target %q", handshake)
+	}
+}
+
+// serveSOCKS5 serves a connect with username and password authentication, and sends them with the target
+func serveSOCKS5(conn net.Conn, handshakes chan<- [2]string) {
+	buf := make([]byte, 512)
+	read := func(n int) []byte {
+		_, _ = io.ReadFull(conn, buf[:n])
+		return buf[:n]
+	}
+	methods := read(2)[1]
+	read(int(methods))
+	_, _ = conn.Write([]byte{5, 2})
+	read(1)
+	username := string(read(int(read(1)[0])))
+	password := string(read(int(read(1)[0])))
+	_, _ = conn.Write([]byte{1, 0})
+	header := read(4)
+	if header[3] != 3 {
+		return
+	}
+	host := string(read(int(read(1)[0])))
+	p := read(2)
+	target := net.JoinHostPort(host, strconv.Itoa(int(p[0])<<8|int(p[1])))
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	handshakes <- [2]string{username + ":" + password, target}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
@@ -50,6 +50,9 @@ type Req struct {
 	ctx         context.Context
 	body        []byte
 	lazyBody    interface{} // 仅将内容原封不动的保存在Req中，交由Handler对lazyBody处理后在转换为实际的Request中的body
+	handlers    HandlerChain
+	hooks       hooks
+	proxy       *url.URL // proxy of this request, in place of the client's
 }
 
 // FileUpload represents a file to upload
@@ -76,13 +79,27 @@ func New() *Req {
 }
 
 // Use 仅在当前请求范围生效的中间件，不会改变到DefaultClient
+// handlers are spliced into the client's chain just before the request is sent,
+// so the client's transport, cookie jar and options are shared.
 func (r *Req) Use(handlers ...HandlerFunc) *Req {
-	c := r.client.Clone()
-	c.Use(handlers...)
-	r.WithClient(c)
+	r.handlers = append(r.handlers, handlers...)
 	return r
 }
 
+// Clone returns a copy of the request which can be changed and sent independently,
+// except for upload files which are shared.
+func (r *Req) Clone() *Req {
+	r2 := *r
+	r2.queryParams = cloneValues(r.queryParams)
+	r2.formParams = cloneValues(r.formParams)
+	r2.header = r.header.Clone()
+	r2.cookies = append([]*http.Cookie(nil), r.cookies...)
+	r2.uploads = append([]FileUpload(nil), r.uploads...)
+	r2.handlers = append(HandlerChain(nil), r.handlers...)
+	r2.hooks = r.hooks.clone()
+	return &r2
+}
+
 // WithName to identify this request, for trace mostly.
 func (r *Req) WithName(name string) *Req {
 	r.name = name
@@ -119,6 +136,18 @@ func (r *Req) WithContext(ctx context.Context) *Req {
 	return r
 }
 
+// WithProxy sends this request through a http, https, socks5 or socks5h proxy, in place of the client's proxies.
+// It has no effect with a custom transport set by WithTransport.
+func (r *Req) WithProxy(proxyURL string) *Req {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.proxy = u
+	return r
+}
+
 // WithClient with client
 func (r *Req) WithClient(c Client) *Req {
 	r.client = c
@@ -459,12 +488,22 @@ func (r *Req) GetClient() Client {
 	return r.client
 }
 
+// GetHandlers return request scoped handlers
+func (r *Req) GetHandlers() HandlerChain {
+	return r.handlers
+}
+
 func (r *Req) Error() error {
 	return r.err
 }
 
 // Build request
 func (r *Req) Build() (*http.Request, error) {
+	return r.build("")
+}
+
+// build builds the request sent to endpoint, the base url picked from the client's endpoints if any
+func (r *Req) build(endpoint string) (*http.Request, error) {
 	request := &http.Request{
 		Header:     make(http.Header),
 		Proto:      "HTTP/1.1",
@@ -475,10 +514,7 @@ func (r *Req) Build() (*http.Request, error) {
 	if r.err != nil {
 		return request, r.err
 	}
-	rawURL := r.rawURL
-	if r.client.Options().PrefixPath != "" {
-		rawURL = r.client.Options().PrefixPath + r.rawURL
-	}
+	rawURL := r.baseURL(endpoint)
 	if rawURL == "" {
 		return request, ErrNoURL
 	}
@@ -486,19 +522,15 @@ func (r *Req) Build() (*http.Request, error) {
 	if r.ctx != nil {
 		request = request.WithContext(r.ctx)
 	}
+	if r.proxy != nil {
+		request = request.WithContext(context.WithValue(request.Context(), proxyContextKey{}, r.proxy))
+	}
 	if len(r.cookies) > 0 {
 		for _, c := range r.cookies {
 			request.AddCookie(c)
 		}
 	}
-	if len(r.queryParams) > 0 {
-		paramStr := r.queryParams.Encode()
-		if strings.IndexByte(rawURL, '?') == -1 {
-			rawURL = rawURL + "?" + paramStr
-		} else {
-			rawURL = rawURL + "&" + paramStr
-		}
-	}
+	rawURL = r.appendQuery(rawURL)
 	if len(r.uploads) > 0 && (request.Method == "POST" || request.Method == "PUT") {
 		body := new(bytes.Buffer)
 		bodyWriter := multipart.NewWriter(body)
@@ -531,18 +563,30 @@ func (r *Req) Build() (*http.Request, error) {
 		}
 	}
 	if len(r.body) > 0 {
-		request.Body = io.NopCloser(bytes.NewReader(r.body))
-		request.ContentLength = int64(len(r.body))
+		body := r.body
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		request.ContentLength = int64(len(body))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
 	}
 	if r.header != nil {
 		request.Header = r.header
 	}
 
+	socket, rawURL, unix, err := rewriteUnixURL(rawURL)
+	if err != nil {
+		return request, err
+	}
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return request, err
 	}
 	request.URL = u
+	if unix {
+		request = request.WithContext(context.WithValue(request.Context(), unixSocketContextKey{}, socket))
+		request.Host = "localhost"
+	}
 
 	if host := request.Header.Get("Host"); host != "" {
 		request.Host = host
@@ -550,6 +594,37 @@ func (r *Req) Build() (*http.Request, error) {
 	return request, nil
 }
 
+// baseURL returns the raw url with the picked endpoint or the client's prefix path
+func (r *Req) baseURL(endpoint string) string {
+	if endpoint != "" {
+		if _, path, ok := splitServiceURL(r.rawURL); ok {
+			return endpoint + path
+		}
+		return endpoint + r.rawURL
+	}
+	if r.client != nil && r.client.Options().PrefixPath != "" {
+		return r.client.Options().PrefixPath + r.rawURL
+	}
+	return r.rawURL
+}
+
+// isAbsolute returns whether the raw url has a scheme, so that no endpoint is put in front of it
+func (r *Req) isAbsolute() bool {
+	return strings.Contains(r.rawURL, "://")
+}
+
+// appendQuery appends the query params to rawURL
+func (r *Req) appendQuery(rawURL string) string {
+	if len(r.queryParams) == 0 {
+		return rawURL
+	}
+	paramStr := r.queryParams.Encode()
+	if strings.IndexByte(rawURL, '?') == -1 {
+		return rawURL + "?" + paramStr
+	}
+	return rawURL + "&" + paramStr
+}
+
 // Do is to call the request
 func (r *Req) Do() *Resp {
 	if r.client == nil {
@@ -558,6 +633,17 @@ func (r *Req) Do() *Resp {
 	return r.client.Do(r)
 }
 
+func cloneValues(values url.Values) url.Values {
+	if values == nil {
+		return nil
+	}
+	values2 := make(url.Values, len(values))
+	for k, v := range values {
+		values2[k] = append([]string(nil), v...)
+	}
+	return values2
+}
+
 func toString(v interface{}) string {
 	switch vv := v.(type) {
 	case nil:
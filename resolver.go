@@ -0,0 +1,236 @@
+package goreq
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServiceScheme is the scheme of the logical urls, like svc://orders/v1/items, resolved by the client's Resolver
+const ServiceScheme = "svc"
+
+// Resolver returns the endpoints of a service, it can be implemented for registries like Consul or etcd
+type Resolver interface {
+	Resolve(ctx context.Context, service string) ([]Endpoint, error)
+}
+
+// ResolverFunc adapts a function to a Resolver
+type ResolverFunc func(ctx context.Context, service string) ([]Endpoint, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	return f(ctx, service)
+}
+
+// StaticResolver resolves services from a fixed list of endpoints
+func StaticResolver(services map[string][]Endpoint) Resolver {
+	return ResolverFunc(func(ctx context.Context, service string) ([]Endpoint, error) {
+		endpoints, ok := services[service]
+		if !ok {
+			return nil, ServiceError{Service: service}
+		}
+		return endpoints, nil
+	})
+}
+
+type fileResolver struct {
+	path     string
+	mu       sync.Mutex
+	modTime  time.Time
+	services map[string][]Endpoint
+}
+
+// FileResolver resolves services from a json file like {"orders": [{"url": "http://10.0.0.1:8080", "weight": 1}]},
+// the file is read again whenever it changed
+func FileResolver(path string) Resolver {
+	return &fileResolver{path: path}
+}
+
+func (r *fileResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if r.services == nil || !info.ModTime().Equal(r.modTime) {
+		data, err := os.ReadFile(r.path)
+		if err != nil {
+			return nil, err
+		}
+		services := make(map[string][]Endpoint)
+		if err = json.Unmarshal(data, &services); err != nil {
+			return nil, err
+		}
+		r.services, r.modTime = services, info.ModTime()
+	}
+	endpoints, ok := r.services[service]
+	if !ok {
+		return nil, ServiceError{Service: service}
+	}
+	return endpoints, nil
+}
+
+type dnsResolver struct {
+	domain   string
+	scheme   string
+	resolver *net.Resolver
+}
+
+// DNSResolver resolves a service from the SRV records _service._tcp.domain, or from the SRV records
+// of the service itself when it starts with an underscore. Only the records of the lowest priority are used,
+// with their weights, and the urls have the given scheme. A nil resolver uses net.DefaultResolver.
+func DNSResolver(domain, scheme string, resolver *net.Resolver) Resolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &dnsResolver{domain: domain, scheme: scheme, resolver: resolver}
+}
+
+func (r *dnsResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	var records []*net.SRV
+	var err error
+	if strings.HasPrefix(service, "_") {
+		_, records, err = r.resolver.LookupSRV(ctx, "", "", service)
+	} else {
+		_, records, err = r.resolver.LookupSRV(ctx, service, "tcp", r.domain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// LookupSRV sorts the records by priority
+	var endpoints []Endpoint
+	for _, srv := range records {
+		if srv.Priority != records[0].Priority {
+			break
+		}
+		host := strings.TrimSuffix(srv.Target, ".")
+		endpoints = append(endpoints, Endpoint{
+			URL:    r.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+			Weight: int(srv.Weight),
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil, ServiceError{Service: service}
+	}
+	return endpoints, nil
+}
+
+// ServiceError is returned when a service has no endpoints
+type ServiceError struct {
+	Service string
+}
+
+func (e ServiceError) Error() string {
+	return "client: no endpoints for service " + e.Service
+}
+
+// services caches an endpoint pool per resolved service, refreshed in the background.
+// It is shared by the clones of a client, and its pools are closed once the last client released it.
+type services struct {
+	resolver    Resolver
+	interval    time.Duration
+	poolOptions []PoolOption
+	refs        int32
+	mu          sync.Mutex
+	pools       map[string]*servicePool
+	closed      bool
+}
+
+type servicePool struct {
+	pool       *EndpointPool
+	resolvedAt time.Time
+	refreshing bool
+}
+
+func newServices(options Options) *services {
+	return &services{
+		resolver:    options.Resolver,
+		interval:    options.ResolveInterval,
+		poolOptions: options.ResolverPoolOptions,
+		refs:        1,
+		pools:       make(map[string]*servicePool),
+	}
+}
+
+func (s *services) acquire() *services {
+	atomic.AddInt32(&s.refs, 1)
+	return s
+}
+
+// release closes the pools, and stops their health checks, when no client uses them anymore
+func (s *services) release() {
+	if s == nil || atomic.AddInt32(&s.refs, -1) > 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, sp := range s.pools {
+		sp.pool.Close()
+	}
+}
+
+// pool returns the endpoints of service, resolving them on the first request,
+// then in the background once they are older than the interval. Stale endpoints are kept on errors.
+func (s *services) pool(ctx context.Context, service string) (*EndpointPool, error) {
+	s.mu.Lock()
+	sp, ok := s.pools[service]
+	if ok {
+		if !sp.refreshing && s.interval > 0 && time.Since(sp.resolvedAt) >= s.interval {
+			sp.refreshing = true
+			go s.refresh(service, sp)
+		}
+		s.mu.Unlock()
+		return sp.pool, nil
+	}
+	s.mu.Unlock()
+
+	endpoints, err := s.resolver.Resolve(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sp, ok := s.pools[service]; ok {
+		// resolved concurrently
+		return sp.pool, nil
+	}
+	sp = &servicePool{pool: NewEndpointPool(endpoints, s.poolOptions...), resolvedAt: time.Now()}
+	s.pools[service] = sp
+	if s.closed {
+		// resolved while the client was initialized again
+		sp.pool.Close()
+	}
+	return sp.pool, nil
+}
+
+func (s *services) refresh(service string, sp *servicePool) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+	endpoints, err := s.resolver.Resolve(ctx, service)
+	if err == nil && len(endpoints) > 0 {
+		sp.pool.Update(endpoints)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp.refreshing = false
+	sp.resolvedAt = time.Now()
+}
+
+// splitServiceURL splits svc://service/path into its service and path
+func splitServiceURL(rawURL string) (service, path string, ok bool) {
+	rest := strings.TrimPrefix(rawURL, ServiceScheme+"://")
+	if len(rest) == len(rawURL) {
+		return "", "", false
+	}
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		return rest[:i], rest[i:], true
+	}
+	return rest, "", true
+}
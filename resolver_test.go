@@ -0,0 +1,98 @@
+package goreq
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileResolver(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + r.URL.RequestURI()))
+		}))
+	}
+	a, b := newServer("a"), newServer("b")
+	defer a.Close()
+	defer b.Close()
+	path := filepath.Join(t.TempDir(), "services.json")
+	write := func(url string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(`{"orders": [{"url": "`+url+`"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a.URL, time.Now().Add(-time.Hour))
+
+	c := NewClient(WithResolver(FileResolver(path)), WithResolveInterval(time.Millisecond))
+	if body := c.Get("svc://orders/v1/items?id=1").Do().String(); body != "a /v1/items?id=1" {
+		t.Fatalf("unexpected response %q", body)
+	}
+
+	write(b.URL, time.Now())
+	time.Sleep(2 * time.Millisecond)
+	c.Get("svc://orders/v1/items").Do() // triggers the refresh
+	deadline := time.Now().Add(time.Second)
+	for c.Get("svc://orders/").Do().String() != "b /" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the endpoints to be refreshed from the file")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var serviceErr ServiceError
+	if err := c.Get("svc://users/").Do().Error(); !errors.As(err, &serviceErr) || serviceErr.Service != "users" {
+		t.Errorf("expected a ServiceError, got %v", err)
+	}
+	if err := NewClient().Get("svc://orders/").Do().Error(); err != ErrNoResolver {
+		t.Errorf("expected ErrNoResolver, got %v", err)
+	}
+}
+
+func TestServicesRelease(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	resolver := StaticResolver(map[string][]Endpoint{"orders": {{URL: ts.URL}}})
+	opts := []Option{WithResolver(resolver, WithHealthCheck("/health", time.Hour, time.Second))}
+
+	// healthChecks waits for the number of health check goroutines to settle to n
+	healthChecks := func(n int) int {
+		buf := make([]byte, 1<<20)
+		deadline := time.Now().Add(time.Second)
+		for {
+			count := strings.Count(string(buf[:runtime.Stack(buf, true)]), "healthCheckLoop")
+			if count == n || time.Now().After(deadline) {
+				return count
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	c := NewClient(opts...)
+	c.Get("svc://orders/").Do()
+	clone := c.Clone()
+	clone.Get("svc://orders/").Do()
+	if n := healthChecks(1); n != 1 {
+		t.Fatalf("expected the clone to share the health check, got %d", n)
+	}
+	for i := 0; i < 3; i++ {
+		_ = c.Init()
+		c.Get("svc://orders/").Do()
+	}
+	if n := healthChecks(2); n != 2 {
+		t.Fatalf("expected the pools replaced by Init to be closed, got %d health checks", n)
+	}
+	_ = clone.Init()
+	_ = c.Init(WithResolveInterval(time.Minute))
+	if n := healthChecks(0); n != 0 {
+		t.Fatalf("expected the pools to be closed, got %d health checks", n)
+	}
+}
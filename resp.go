@@ -33,21 +33,38 @@ func (r *Resp) Response() *http.Response {
 	return r.response
 }
 
-// StatusCode returns status code
+// StatusCode returns status code, 0 if no response received
 func (r *Resp) StatusCode() int {
+	if r.response == nil {
+		return 0
+	}
 	return r.Response().StatusCode
 }
 
 // ContentLength returns content length
 func (r *Resp) ContentLength() int64 {
+	if r.response == nil {
+		return 0
+	}
 	return r.Response().ContentLength
 }
 
 // ContentType returns content type
 func (r *Resp) ContentType() string {
+	if r.response == nil {
+		return ""
+	}
 	return r.Response().Header.Get(ContentType)
 }
 
+// Proto returns the negotiated protocol version, like HTTP/1.1 or HTTP/2.0
+func (r *Resp) Proto() string {
+	if r.response == nil {
+		return ""
+	}
+	return r.response.Proto
+}
+
 // Timeout returns true if timeout
 func (r *Resp) Timeout() bool {
 	return r.timeout
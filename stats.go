@@ -0,0 +1,7 @@
+package goreq
+
+// Stats of a client, for metrics plugins
+type Stats struct {
+	DNS   DNSStats
+	Hosts map[string]HostStats // connections per dialed address
+}
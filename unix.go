@@ -0,0 +1,48 @@
+package goreq
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// UnixSocketScheme is the scheme of the urls sent over a unix socket, like http+unix://%2Fvar%2Frun%2Fdocker.sock/info
+// where the host is the escaped path of the socket
+const UnixSocketScheme = "http+unix"
+
+type unixSocketContextKey struct{}
+
+// DialContext is the signature of net.Dialer.DialContext
+type DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// rewriteUnixURL returns the socket of a http+unix url and the url to send, which host is the hex encoded socket,
+// so that the transport keeps the connections of every socket apart
+func rewriteUnixURL(rawURL string) (socket, rewritten string, ok bool, err error) {
+	rest := strings.TrimPrefix(rawURL, UnixSocketScheme+"://")
+	if len(rest) == len(rawURL) {
+		return "", rawURL, false, nil
+	}
+	host, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if socket, err = url.PathUnescape(host); err != nil {
+		return "", rawURL, false, err
+	}
+	return socket, "http://" + hex.EncodeToString([]byte(socket)) + path, true, nil
+}
+
+// unixDialContext dials the unix socket of the request when there is one, or the socket of the client if set
+func unixDialContext(socket string, dial DialContext) DialContext {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if s, ok := ctx.Value(unixSocketContextKey{}).(string); ok {
+			return dial(ctx, "unix", s)
+		}
+		if socket != "" {
+			return dial(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,57 @@
+package goreq
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host + " " + r.URL.RequestURI()))
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	var dials int32
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	// proxies are not used for unix sockets
+	c := NewClient(WithDialContext(dial), WithProxyURL("http://127.0.0.1:1"))
+	rawURL := UnixSocketScheme + "://" + url.PathEscape(socket) + "/v1/info"
+	if body := c.Get(rawURL).WithQueryParam("all", 1).Do().String(); body != "localhost /v1/info?all=1" {
+		t.Fatalf("unexpected response %q", body)
+	}
+	if atomic.LoadInt32(&dials) != 1 {
+		t.Errorf("expected the custom dialer to be used, got %d dials", dials)
+	}
+
+	c = NewClient(WithUnixSocket(socket))
+	if body := c.Get("http://docker/info").Do().String(); body != "docker /info" {
+		t.Fatalf("unexpected response %q", body)
+	}
+
+	// the dns options do not apply to the socket paths
+	c = NewClient(WithUnixSocket(socket), WithDNSCacheTTL(time.Minute), WithHostOverride("docker", "127.0.0.1"))
+	if body := c.Get("http://docker/info").Do().String(); body != "docker /info" {
+		t.Fatalf("unexpected response %q", body)
+	}
+	c = NewClient(WithDNSCacheTTL(time.Minute))
+	if body := c.Get(rawURL).Do().String(); body != "localhost /v1/info" {
+		t.Fatalf("unexpected response %q", body)
+	}
+}